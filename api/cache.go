@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CoordinatePrecision je broj decimala na koji zaokružujemo koordinate
+// točke prije dohvata prognoze. Dvije decimale su oko 1 km, što je
+// manje od razlučivosti modela svih izvora prognoze.
+const CoordinatePrecision = 2
+
+// ForecastCache pamti prognoze za proizvoljne točke, kako upiti za
+// bliske točke ne bi trošili ograničenje poziva prema izvoru. Prognozu
+// dohvaća za cijeli interval automatskog ažuriranja, a svaki upit iz nje
+// uzima samo svoj interval. Istovremeni upiti za istu točku čekaju
+// jedan dohvat umjesto da svaki poziva izvor.
+type ForecastCache struct {
+	Provider WeatherProvider
+	TTL      time.Duration
+
+	mu      sync.Mutex
+	entries map[Location]*forecastCacheEntry
+}
+
+// forecastCacheEntry je zapamćena prognoza za jednu zaokruženu točku.
+// Kanal done se zatvara kada je dohvat gotov.
+type forecastCacheEntry struct {
+	done      chan struct{}
+	data      []WeatherData
+	err       error
+	fetchedAt time.Time
+}
+
+// NewForecastCache kreira prazan spremnik prognoza. TTL 0 isključuje
+// pamćenje, ali istovremeni upiti za istu točku i dalje dijele dohvat.
+func NewForecastCache(provider WeatherProvider, ttl time.Duration) *ForecastCache {
+	return &ForecastCache{
+		Provider: provider,
+		TTL:      ttl,
+		entries:  make(map[Location]*forecastCacheEntry),
+	}
+}
+
+// roundPoint vraća koordinate točke zaokružene na CoordinatePrecision decimala
+func roundPoint(p Point) Location {
+	return Location{
+		Lat: strconv.FormatFloat(p.Lat, 'f', CoordinatePrecision, 64),
+		Lon: strconv.FormatFloat(p.Lon, 'f', CoordinatePrecision, 64),
+	}
+}
+
+// Forecast vraća prognoze za zaokruženu točku i vrijeme njihovog dohvata.
+// Vraćeni niz dijele svi upiti pa ga pozivatelj ne smije mijenjati.
+func (fc *ForecastCache) Forecast(ctx context.Context, key Location) ([]WeatherData, time.Time, error) {
+
+	fc.mu.Lock()
+	entry := fc.entries[key]
+	if entry == nil || fc.expired(entry) {
+		entry = &forecastCacheEntry{done: make(chan struct{})}
+		fc.entries[key] = entry
+		fc.mu.Unlock()
+		go fc.fetch(key, entry)
+	} else {
+		fc.mu.Unlock()
+	}
+
+	select {
+	case <-entry.done:
+		return entry.data, entry.fetchedAt, entry.err
+	case <-ctx.Done():
+		return nil, time.Time{}, ctx.Err()
+	}
+}
+
+// expired javlja da li je dohvat gotov i prognoza istekla.
+// Poziva se samo dok je fc.mu zaključan.
+func (fc *ForecastCache) expired(entry *forecastCacheEntry) bool {
+	select {
+	case <-entry.done:
+		return time.Since(entry.fetchedAt) >= fc.TTL
+	default:
+		return false
+	}
+}
+
+// fetch dohvaća prognozu za točku i briše istekle prognoze. Neuspjeli
+// dohvat ne pamtimo, pa idući upit za istu točku pokušava ponovno.
+// Dohvat ne prekidamo kad odustane upit koji ga je pokrenuo,
+// jer na njega mogu čekati i drugi upiti.
+func (fc *ForecastCache) fetch(key Location, entry *forecastCacheEntry) {
+	start, end := updateInterval()
+	entry.data, entry.err = fc.Provider.Forecast(context.Background(), key.Lat, key.Lon, start, end)
+	entry.fetchedAt = time.Now()
+	close(entry.done)
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if entry.err != nil && fc.entries[key] == entry {
+		delete(fc.entries, key)
+	}
+	for k, e := range fc.entries {
+		if fc.expired(e) {
+			delete(fc.entries, k)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRoundPoint(t *testing.T) {
+	if got := roundPoint(Point{45.81549, -15.98451}); got != (Location{"45.82", "-15.98"}) {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestForecastCacheSharesFetch(t *testing.T) {
+	p := &fakeProvider{release: make(chan struct{})}
+	fc := NewForecastCache(p, time.Hour)
+	key := Location{"45.81", "15.98"}
+
+	// Svi istovremeni upiti čekaju isti dohvat
+	var wg sync.WaitGroup
+	results := make([][]WeatherData, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data, _, err := fc.Forecast(context.Background(), key)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = data
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(p.release)
+	wg.Wait()
+
+	if p.Calls() != 1 {
+		t.Errorf("got %d calls, want 1", p.Calls())
+	}
+	for i, data := range results {
+		if len(data) == 0 || len(data) != len(results[0]) {
+			t.Errorf("request %d got %d forecasts", i, len(data))
+		}
+	}
+
+	// Dok prognoza ne istekne, novi upit ne poziva izvor
+	if _, _, err := fc.Forecast(context.Background(), key); err != nil || p.Calls() != 1 {
+		t.Errorf("got %d calls and %v, want the cached forecast", p.Calls(), err)
+	}
+}
+
+func TestForecastCacheExpiresAndForgetsErrors(t *testing.T) {
+	p := &fakeProvider{err: errors.New("nedostupan")}
+	fc := NewForecastCache(p, 0)
+	key := Location{"45.81", "15.98"}
+
+	if _, _, err := fc.Forecast(context.Background(), key); err == nil {
+		t.Fatal("expected the provider error")
+	}
+	p.mu.Lock()
+	p.err = nil
+	p.mu.Unlock()
+
+	// Greška se ne pamti, a s TTL 0 svaki upit ponovno dohvaća prognozu
+	for i := 0; i < 2; i++ {
+		if _, _, err := fc.Forecast(context.Background(), key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p.Calls() != 3 {
+		t.Errorf("got %d calls, want 3", p.Calls())
+	}
+}
+
+func TestForecastCacheCancelledRequest(t *testing.T) {
+	p := &fakeProvider{release: make(chan struct{})}
+	fc := NewForecastCache(p, time.Hour)
+	key := Location{"45.81", "15.98"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := fc.Forecast(ctx, key); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	// Dohvat se nastavlja i za iduće upite
+	close(p.release)
+	if _, _, err := fc.Forecast(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	if p.Calls() != 1 {
+		t.Errorf("got %d calls, want 1", p.Calls())
+	}
+}
@@ -0,0 +1,238 @@
+package api
+
+import "strings"
+
+// Kategorije vremena koje ne ovise o izvoru prognoze
+const (
+	ConditionClear         = "clear"
+	ConditionPartlyCloudy  = "partly_cloudy"
+	ConditionCloudy        = "cloudy"
+	ConditionHaze          = "haze"
+	ConditionFog           = "fog"
+	ConditionDrizzle       = "drizzle"
+	ConditionFreezingRain  = "freezing_rain"
+	ConditionRain          = "rain"
+	ConditionSleet         = "sleet"
+	ConditionSnow          = "snow"
+	ConditionRainShowers   = "rain_showers"
+	ConditionSnowShowers   = "snow_showers"
+	ConditionSquall        = "squall"
+	ConditionThunderstorm  = "thunderstorm"
+	ConditionTornado       = "tornado"
+	DefaultDescriptionLang = "hr"
+)
+
+// conditionSeverity poredava kategorije vremena od najblaže prema najgoroj
+var conditionSeverity = map[string]int{
+	ConditionClear:        1,
+	ConditionPartlyCloudy: 2,
+	ConditionCloudy:       3,
+	ConditionHaze:         4,
+	ConditionFog:          5,
+	ConditionDrizzle:      6,
+	ConditionRain:         7,
+	ConditionRainShowers:  8,
+	ConditionSnow:         9,
+	ConditionSnowShowers:  10,
+	ConditionSleet:        11,
+	ConditionFreezingRain: 12,
+	ConditionSquall:       13,
+	ConditionThunderstorm: 14,
+	ConditionTornado:      15,
+}
+
+// conditionInfo opisuje jedan WMO kod vremena
+type conditionInfo struct {
+	category     string
+	descriptions map[string]string
+}
+
+// wmoConditions su WMO kodovi vremena (tablica 4677) koje koristimo, s kategorijom
+// i opisom na svakom podržanom jeziku. Svi izvori svoje kodove pretvaraju u ove.
+var wmoConditions = map[int]conditionInfo{
+	0:  {ConditionClear, map[string]string{"hr": "vedro", "en": "clear sky"}},
+	1:  {ConditionClear, map[string]string{"hr": "pretežno vedro", "en": "mainly clear"}},
+	2:  {ConditionPartlyCloudy, map[string]string{"hr": "djelomično oblačno", "en": "partly cloudy"}},
+	3:  {ConditionCloudy, map[string]string{"hr": "oblačno", "en": "overcast"}},
+	4:  {ConditionHaze, map[string]string{"hr": "dim", "en": "smoke"}},
+	5:  {ConditionHaze, map[string]string{"hr": "sumaglica", "en": "haze"}},
+	6:  {ConditionHaze, map[string]string{"hr": "prašina", "en": "dust"}},
+	7:  {ConditionHaze, map[string]string{"hr": "pješčana oluja", "en": "sand or dust storm"}},
+	10: {ConditionFog, map[string]string{"hr": "sumaglica", "en": "mist"}},
+	18: {ConditionSquall, map[string]string{"hr": "olujni vjetar", "en": "squalls"}},
+	19: {ConditionTornado, map[string]string{"hr": "tornado", "en": "tornado"}},
+	45: {ConditionFog, map[string]string{"hr": "magla", "en": "fog"}},
+	48: {ConditionFog, map[string]string{"hr": "magla s injem", "en": "depositing rime fog"}},
+	51: {ConditionDrizzle, map[string]string{"hr": "slaba rosulja", "en": "light drizzle"}},
+	53: {ConditionDrizzle, map[string]string{"hr": "rosulja", "en": "drizzle"}},
+	55: {ConditionDrizzle, map[string]string{"hr": "jaka rosulja", "en": "dense drizzle"}},
+	56: {ConditionFreezingRain, map[string]string{"hr": "ledena rosulja", "en": "light freezing drizzle"}},
+	57: {ConditionFreezingRain, map[string]string{"hr": "jaka ledena rosulja", "en": "dense freezing drizzle"}},
+	61: {ConditionRain, map[string]string{"hr": "slaba kiša", "en": "light rain"}},
+	63: {ConditionRain, map[string]string{"hr": "kiša", "en": "rain"}},
+	65: {ConditionRain, map[string]string{"hr": "jaka kiša", "en": "heavy rain"}},
+	66: {ConditionFreezingRain, map[string]string{"hr": "ledena kiša", "en": "light freezing rain"}},
+	67: {ConditionFreezingRain, map[string]string{"hr": "jaka ledena kiša", "en": "heavy freezing rain"}},
+	68: {ConditionSleet, map[string]string{"hr": "slaba susnježica", "en": "light sleet"}},
+	69: {ConditionSleet, map[string]string{"hr": "susnježica", "en": "sleet"}},
+	71: {ConditionSnow, map[string]string{"hr": "slab snijeg", "en": "light snow"}},
+	73: {ConditionSnow, map[string]string{"hr": "snijeg", "en": "snow"}},
+	75: {ConditionSnow, map[string]string{"hr": "jak snijeg", "en": "heavy snow"}},
+	77: {ConditionSnow, map[string]string{"hr": "zrnati snijeg", "en": "snow grains"}},
+	80: {ConditionRainShowers, map[string]string{"hr": "slabi pljuskovi", "en": "light rain showers"}},
+	81: {ConditionRainShowers, map[string]string{"hr": "pljuskovi", "en": "rain showers"}},
+	82: {ConditionRainShowers, map[string]string{"hr": "jaki pljuskovi", "en": "violent rain showers"}},
+	85: {ConditionSnowShowers, map[string]string{"hr": "snježni pljuskovi", "en": "snow showers"}},
+	86: {ConditionSnowShowers, map[string]string{"hr": "jaki snježni pljuskovi", "en": "heavy snow showers"}},
+	95: {ConditionThunderstorm, map[string]string{"hr": "grmljavina", "en": "thunderstorm"}},
+	96: {ConditionThunderstorm, map[string]string{"hr": "grmljavina s tučom", "en": "thunderstorm with hail"}},
+	99: {ConditionThunderstorm, map[string]string{"hr": "jaka grmljavina s tučom", "en": "thunderstorm with heavy hail"}},
+}
+
+// conditionCategory vraća kategoriju vremena za WMO kod
+func conditionCategory(code int) string {
+	if info, ok := wmoConditions[code]; ok {
+		return info.category
+	}
+	return ""
+}
+
+// describeCondition vraća opis vremena za WMO kod na zadanom jeziku.
+// Ako jezik nije podržan vraća opis na hrvatskom.
+func describeCondition(code int, lang string) string {
+	info, ok := wmoConditions[code]
+	if !ok {
+		return ""
+	}
+	if desc, ok := info.descriptions[lang]; ok {
+		return desc
+	}
+	return info.descriptions[DefaultDescriptionLang]
+}
+
+// setCondition postavlja WMO kod, kategoriju i hrvatski opis vremena.
+// Za nepoznati kod zadržava opis koji je dao izvor.
+func (d *WeatherData) setCondition(code int) {
+	d.WMOCode = code
+	d.Condition = conditionCategory(code)
+	if desc := describeCondition(code, DefaultDescriptionLang); desc != "" {
+		d.WeatherIcon = desc
+	} else if d.WeatherIcon == "" {
+		d.WeatherIcon = "nepoznato"
+	}
+}
+
+// Localize mijenja opis vremena u opis na zadanom jeziku.
+// Prognoze bez poznate kategorije zadržavaju opis koji je dao izvor.
+func (d *WeatherData) Localize(lang string) {
+	if d.Condition == "" {
+		return
+	}
+	d.WeatherIcon = describeCondition(d.WMOCode, lang)
+}
+
+// owmToWMO pretvara Open Weather kod vremena u WMO kod
+func owmToWMO(id int) int {
+	switch {
+	case id == 202 || id == 212 || id == 232:
+		return 96
+	case id >= 200 && id < 300:
+		return 95
+	case id == 300 || id == 310:
+		return 51
+	case id == 301 || id == 311 || id == 313 || id == 321:
+		return 53
+	case id >= 300 && id < 400:
+		return 55
+	case id == 500:
+		return 61
+	case id == 501:
+		return 63
+	case id >= 502 && id <= 504:
+		return 65
+	case id == 511:
+		return 66
+	case id == 520:
+		return 80
+	case id == 521:
+		return 81
+	case id >= 522 && id < 600:
+		return 82
+	case id == 600:
+		return 71
+	case id == 601:
+		return 73
+	case id == 602:
+		return 75
+	case id == 611 || id == 612 || id == 615:
+		return 68
+	case id == 613 || id == 616:
+		return 69
+	case id == 620 || id == 621:
+		return 85
+	case id == 622:
+		return 86
+	case id == 701:
+		return 10
+	case id == 711 || id == 762:
+		return 4
+	case id == 721:
+		return 5
+	case id == 731 || id == 751 || id == 761:
+		return 6
+	case id == 741:
+		return 45
+	case id == 771:
+		return 18
+	case id == 781:
+		return 19
+	case id == 800:
+		return 0
+	case id == 801:
+		return 1
+	case id == 802:
+		return 2
+	case id == 803 || id == 804:
+		return 3
+	default:
+		return -1
+	}
+}
+
+// metNoCodes su WMO kodovi za simbole MET Norway API-ja
+var metNoCodes = map[string]int{
+	"clearsky":          0,
+	"fair":              1,
+	"partlycloudy":      2,
+	"cloudy":            3,
+	"fog":               45,
+	"lightrain":         61,
+	"rain":              63,
+	"heavyrain":         65,
+	"lightrainshowers":  80,
+	"rainshowers":       81,
+	"heavyrainshowers":  82,
+	"lightsleet":        68,
+	"sleet":             69,
+	"heavysleet":        69,
+	"lightsleetshowers": 68,
+	"sleetshowers":      69,
+	"heavysleetshowers": 69,
+	"lightsnow":         71,
+	"snow":              73,
+	"heavysnow":         75,
+	"lightsnowshowers":  85,
+	"snowshowers":       85,
+	"heavysnowshowers":  86,
+}
+
+// metNoToWMO pretvara MET Norway simbol, bez dodatka _day ili _night, u WMO kod
+func metNoToWMO(symbol string) int {
+	if strings.Contains(symbol, "thunder") {
+		return 95
+	}
+	if code, ok := metNoCodes[symbol]; ok {
+		return code
+	}
+	return -1
+}
@@ -0,0 +1,78 @@
+package api
+
+import "testing"
+
+func TestOwmToWMO(t *testing.T) {
+	tests := []struct {
+		id   int
+		want int
+	}{
+		{200, 95},
+		{212, 96},
+		{300, 51},
+		{500, 61},
+		{502, 65},
+		{511, 66},
+		{522, 82},
+		{601, 73},
+		{615, 68},
+		{701, 10},
+		{741, 45},
+		{781, 19},
+		{800, 0},
+		{804, 3},
+		{900, -1},
+	}
+	for _, tt := range tests {
+		if got := owmToWMO(tt.id); got != tt.want {
+			t.Errorf("owmToWMO(%d) = %d, want %d", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestMetNoToWMO(t *testing.T) {
+	tests := []struct {
+		symbol string
+		want   int
+	}{
+		{"clearsky", 0},
+		{"lightrainshowers", 80},
+		{"heavysnow", 75},
+		{"rainandthunder", 95},
+		{"heavysleetshowersandthunder", 95},
+		{"nepoznato", -1},
+	}
+	for _, tt := range tests {
+		if got := metNoToWMO(tt.symbol); got != tt.want {
+			t.Errorf("metNoToWMO(%q) = %d, want %d", tt.symbol, got, tt.want)
+		}
+	}
+	if metNoSymbol("fair_polartwilight") != "fair" {
+		t.Error("metNoSymbol did not remove the suffix")
+	}
+}
+
+func TestSetConditionAndLocalize(t *testing.T) {
+	var d WeatherData
+	d.setCondition(95)
+	if d.WMOCode != 95 || d.Condition != ConditionThunderstorm || d.WeatherIcon != "grmljavina" {
+		t.Errorf("unexpected condition %+v", d)
+	}
+	d.Localize("en")
+	if d.WeatherIcon != "thunderstorm" {
+		t.Errorf("english description = %q", d.WeatherIcon)
+	}
+	// Za nepodržani jezik vraćamo hrvatski opis
+	d.Localize("de")
+	if d.WeatherIcon != "grmljavina" {
+		t.Errorf("fallback description = %q", d.WeatherIcon)
+	}
+
+	// Nepoznati kod zadržava opis izvora
+	d = WeatherData{WeatherIcon: "vulkanski pepeo"}
+	d.setCondition(-1)
+	d.Localize("en")
+	if d.Condition != "" || d.WeatherIcon != "vulkanski pepeo" {
+		t.Errorf("unexpected unknown condition %+v", d)
+	}
+}
@@ -1,106 +1,59 @@
 package api
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
+	"context"
 	"log"
-	"net/http"
-	"strconv"
+	"math/rand"
 	"time"
-
-	// Dodatna biblioteka koja nam omogućuje
-	// bolje parsiranje stringova u time varijable
-	"github.com/araddon/dateparse"
 )
 
-// GetWeatherFromOpenWeather dohvaća prognoze za određenu lokaciju,
-// filtrira ih preuzimajući samo one prognoze koje nam trebaju i to vraća.
-func GetWeatherFromOpenWeather(lat, lon, start, end string) (data []WeatherData, err error) {
-
-	// Ovdje unesite svoj API ključ za Open Weather API.
-	apiKey := "????"
-	url := fmt.Sprintf("https://api.openweathermap.org/data/2.5/forecast?lat=%s&lon=%s&units=metric&lang=hr&APPID=%s", lat, lon, apiKey)
-
-	// Incijaliziramo praznu listu strukture WeatherPodcastByPeriod.
-	// U tu listu ćemo spremiti samo one prognoze koje nam odgovaraju
-	filteredForecastData := []WeatherData{}
-
-	// Izrada zahtjeva
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return filteredForecastData, err
-	}
-
-	// Kreiranje HTTP klijenta
-	// Timeout postavljamo na maksimum 2 sekunde,
-	// kako bi izbjegli zastoj servisa u slučaju nedostupnosti poslužitelja
-	client := &http.Client{
-		Timeout: time.Second * 2,
-	}
-	if err != nil {
-		return filteredForecastData, err
-	}
-
-	// Slanje zahtijeva poslužitelju preko klijenta
-	// "Do" šalje HTTP zahtjeva i vraća jedan
-	res, err := client.Do(req)
-	if err != nil {
-		return filteredForecastData, err
-	}
-
-	// Potrebno je zatvoriti res.Body
-	// nakon čitanja podataka iz njega.
-	defer res.Body.Close()
+// RetryPolicy određuje koliko puta i s kolikim razmakom
+// ponovno pokušavamo dohvatiti prognozu za jednu lokaciju.
+type RetryPolicy struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
 
-	var forecastData WeatherPodcast
+// DefaultRetryPolicy je politika ponovnih pokušaja automatskog ažuriranja
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:  3,
+	BaseDelay: 2 * time.Second,
+	MaxDelay:  30 * time.Second,
+}
 
-	// Koristimo json.Decode za čitanje strema JSON podataka.
-	// Pri čitanju spremamo samo one podatke prognoze koje nam trebaju,
-	// a definirani su u strukturi WeatherPodcast
-	if err := json.NewDecoder(res.Body).Decode(&forecastData); err != nil {
-		return filteredForecastData, err
+// delay vraća slučajno vrijeme čekanja prije idućeg pokušaja. Gornja granica
+// raste eksponencijalno, a slučajnost sprječava da svi pokušaji krenu odjednom.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	max := p.BaseDelay << uint(attempt)
+	if max <= 0 || max > p.MaxDelay {
+		max = p.MaxDelay
 	}
+	return time.Duration(rand.Int63n(int64(max) + 1))
+}
 
-	// Preko Weather API-ja primili smo podatke za idućih pet dana,
-	// ali nama trebaju samo podaci za viken i to u intervalu od 9:00 do 18:00.
-
-	temp := WeatherData{}
-	j := 0
-	// Sami odgovor OpenWeather API-ja nam govori kollika je lista prognoza u atributu Cnt
-	for i := 0; i < forecastData.Cnt; i++ {
-
-		// Pretvaramo vrijeme prognoze u tip podataka Time prikladnim za rukovanje
-		t, _ := dateparse.ParseLocal(strconv.Itoa(forecastData.List[i].Dt))
-		var Start, End time.Time
-		if start != "o" {
-			Start, _ = dateparse.ParseLocal(start)
-			End, _ = dateparse.ParseLocal(end)
-		} else {
-			Start = time.Now()
-			year, _, _ := Start.Date()
-			End = time.Date(year+1, 2, 15, 0, 0, 0, 0, time.Local)
+// fetchWithRetry dohvaća prognozu za lokaciju i ponavlja dohvat ako ne uspije
+func fetchWithRetry(provider WeatherProvider, policy RetryPolicy, lat, lon string, start, end time.Time) (data []WeatherData, err error) {
+	for attempt := 0; attempt < policy.Attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(policy.delay(attempt))
 		}
-		// Preuzimamo samo one prognoze koje su
-		// u intervalu utrke
-		if (t.After(Start) || t.Equal(Start)) && (t.Before(End) || t.Equal(End)) && t.Hour() != 21 {
-			temp.Date = t.Format(time.RFC3339)
-			temp.Humidity = forecastData.List[i].Main.Humidity
-			temp.Temp = forecastData.List[i].Main.Temp
-			temp.Rain = forecastData.List[i].Rain.TreeH
-			temp.WindSpeed = forecastData.List[i].Wind.Speed
-			temp.WeatherIcon = forecastData.List[i].Weather[0].Description
-			temp.Snow = forecastData.List[i].Snow.TreeH
-			filteredForecastData = append(filteredForecastData, temp)
-			j++
+		data, err = provider.Forecast(context.Background(), lat, lon, start, end)
+		if err == nil {
+			return data, nil
 		}
+		log.Printf("Neuspješan dohvat prognoze za lokaciju %s, %s (pokušaj %d od %d). Greska:%v",
+			lat, lon, attempt+1, policy.Attempts, err)
 	}
-
-	return filteredForecastData, err
+	return data, err
 }
 
 // AutomaticUpdate vrši automatsko ažuriranje podataka u bazi,
-func AutomaticUpdate() {
+// a prognoze dohvaća preko zadanog izvora prognoze.
+// Lokacije za koje dohvat ne uspije ni nakon ponovnih pokušaja
+// spremamo u red u bazi i pokušavamo ih ponovno pri idućem ažuriranju,
+// a prognoze za sve ostale lokacije se ipak spremaju.
+func AutomaticUpdate(provider WeatherProvider) {
 
 	// Dohvaćamo podatke utrke koje još nisu završile,
 	races, err := GetNotFinishedRaces()
@@ -109,47 +62,55 @@ func AutomaticUpdate() {
 		return
 	}
 
+	// Lokacije koje nisu uspjele u prethodnim ažuriranjima pokušavamo prve
+	retries, err := GetDueRetries()
+	if err != nil {
+		log.Printf("Neuspješan dohvat lokacija za ponovni pokušaj. Greska:%v", err)
+	}
+
+	// Lokacije u redu kojima još nije došlo vrijeme za ponovni pokušaj
+	// preskačemo, kako ne bi zaobišli odgodu između pokušaja
+	waiting, err := GetWaitingRetries()
+	if err != nil {
+		log.Printf("Neuspješan dohvat lokacija koje čekaju ponovni pokušaj. Greska:%v", err)
+	}
+
 	// Kako ne bi za različite utrke na istim lokacijama
 	// dohvaćali iste vremenske podatke, dohvaćene lokacije spremamu
 	// u listu i svaki put provjeravamo.
 	var alredyFetched []Location
-	alredyFetched = append(alredyFetched, Location{})
-	numCalls := 1
 	var allData []AllData
-	for _, race := range races {
-
-		if areAlredyFetched(alredyFetched, Location{race.Lat, race.Lon}) {
-
-			// Besplatna verzija Open Weather API-ja ima ograničene od 60 zahtjeva
-			// po minuti, ako se pređe ta granica, blokira se pristup api-ju.
-			// Kako bi to izbjegli pratimo broj poziva API-ja. Ako se pređe granica
-			// od 40, rutina čeka minutu kako bi nastavila posao. Ovim nije blokirana cijela
-			// aplikacija, nego samo jedna go rutina.
-			// Ostavljamo mogućnost da pored ovih poziva, u isto vrijeme, bude još drugih,
-			// zato ne riskiramo s brojem od 60, nego uzimamo sigurniji broj od 45.
-			if numCalls > 45 {
-				fmt.Println("Velik broj zahtjeva. Pričekajte minutu za nastavak.")
-				time.Sleep(1 * time.Minute)
-				numCalls = 1
-			}
-
-			data, err := GetWeatherFromOpenWeather(race.Lat, race.Lon, "o", "o")
-			if err != nil {
-				log.Printf(`Zaustavljamo pokušaj automatsko ažuriranje. 
-								Neuspješan dohvat vremenske prognoze. Greska:%v`, err)
-				return
-			}
-
-			allData = append(allData, AllData{race.LocID, data})
-			if numCalls == 1 {
-				alredyFetched[0] = Location{race.Lat, race.Lon}
-			} else {
-				alredyFetched = append(alredyFetched, Location{race.Lat, race.Lon})
+	var fetchedIDs []int
+	start, end := updateInterval()
+
+	// Broj poziva prema izvoru prognoze ne pratimo ovdje, jer svaki
+	// poziv prolazi kroz ograničenje koje je zajedničko za cijeli proces.
+	// Ako je ograničenje potrošeno, čeka samo ova go rutina.
+	fetch := func(locID int, lat, lon string) {
+		alredyFetched = append(alredyFetched, Location{lat, lon})
+		data, err := fetchWithRetry(provider, DefaultRetryPolicy, lat, lon, start, end)
+		if err != nil {
+			if err := EnqueueRetry(locID, err); err != nil {
+				log.Printf("Neuspješno spremanje lokacije %d za ponovni pokušaj. Greska:%v", locID, err)
 			}
-			numCalls++
+			return
 		}
+		allData = append(allData, AllData{locID, provider.Name(), data})
+		fetchedIDs = append(fetchedIDs, locID)
+	}
 
+	for _, retry := range retries {
+		fetch(retry.LocID, retry.Lat, retry.Lon)
+	}
+	for _, race := range races {
+		if waiting[race.LocID] {
+			continue
+		}
+		if areAlredyFetched(alredyFetched, Location{race.Lat, race.Lon}) {
+			fetch(race.LocID, race.Lat, race.Lon)
+		}
 	}
+
 	err = UpdateWeather(allData)
 	if err != nil {
 		log.Printf(`Zaustavljamo pokušaj automatsko ažuriranje.
@@ -157,6 +118,13 @@ func AutomaticUpdate() {
 		return
 	}
 
+	// Tek kada su prognoze spremljene, uspješne lokacije uklanjamo iz reda
+	for _, locID := range fetchedIDs {
+		if err := DeleteRetry(locID); err != nil {
+			log.Printf("Neuspješno uklanjanje lokacije %d iz reda. Greska:%v", locID, err)
+		}
+	}
+
 	return
 }
 
@@ -169,42 +137,3 @@ func areAlredyFetched(alredyFetched []Location, location Location) bool {
 	}
 	return true
 }
-
-// CheckData provjerava podataka za CreateRace and UpdateRace
-func CheckData(naziv, lat, lon, pocetak, kraj string) (start, end time.Time, err error) {
-	// Provjera da li ima praznih varijabli
-	if naziv == "" || lat == "" || lon == "" || pocetak == "" || kraj == "" {
-		err = errors.New("nisu poslani svi podatci")
-		return start, end, err
-	}
-
-	// Pretvaramo vrijeme početka i kraja utrke
-	// u tip Time prikladnim za rukovanje
-	start, err = dateparse.ParseLocal(pocetak)
-	end, err = dateparse.ParseLocal(kraj)
-	if err != nil {
-		err = errors.New("format vremena održavanja utrke je nevaljan")
-		return start, end, err
-	}
-
-	// Provjera mogućih grešaka
-	// if int(start.Weekday()) != int(end.Weekday()) {
-	// 	err = errors.New("utrka mora započeti i završiti isti dan")
-	// 	return start, end, err
-	// } else if int(start.Weekday()) != 6 && int(start.Weekday()) != 0 {
-	// 	err = errors.New("utrka se mora održavati vikendom")
-	// 	return start, end, err
-	// } else
-	if start.Before(time.Now()) || end.Before(time.Now()) {
-		err = errors.New("utrka se ne može održavati u prošlosti")
-		return start, end, err
-	} else if start.After(end) {
-		err = errors.New("utrka se ne može prije završiti nego što je počela")
-	} else if start.Equal(end) {
-		err = errors.New("utrka ne može završiti i započeti u isto vrijeme")
-	}
-	//  else if (start.Hour() < 9 || start.Hour() > 18) || (end.Hour() < 9 || end.Hour() > 18) {
-	// 	err = errors.New("utrka se mora održavati između 9 i 18 sati")
-	// }
-	return start, end, err
-}
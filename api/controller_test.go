@@ -0,0 +1,61 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyProvider ne uspijeva prvih failures poziva, a zatim vraća prognozu
+type flakyProvider struct {
+	fakeProvider
+	failures int
+}
+
+func (p *flakyProvider) Forecast(ctx context.Context, lat, lon string, start, end time.Time) ([]WeatherData, error) {
+	if p.Calls() < p.failures {
+		p.mu.Lock()
+		p.calls = append(p.calls, Location{lat, lon})
+		p.mu.Unlock()
+		return []WeatherData{}, errors.New("nedostupan")
+	}
+	return p.fakeProvider.Forecast(ctx, lat, lon, start, end)
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{Attempts: 10, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	for attempt := 0; attempt < 100; attempt++ {
+		// Gornja granica raste s brojem pokušaja, ali nikad preko MaxDelay
+		max := time.Second << uint(attempt)
+		if max <= 0 || max > p.MaxDelay {
+			max = p.MaxDelay
+		}
+		if d := p.delay(attempt); d < 0 || d > max {
+			t.Fatalf("delay(%d) = %v, want at most %v", attempt, d, max)
+		}
+	}
+}
+
+func TestFetchWithRetry(t *testing.T) {
+	policy := RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	start := time.Date(2030, 5, 4, 9, 0, 0, 0, time.UTC)
+
+	p := &flakyProvider{failures: 2}
+	data, err := fetchWithRetry(p, policy, "45.81", "15.98", start, start.Add(time.Hour))
+	if err != nil || len(data) != 2 {
+		t.Fatalf("got %d forecasts and %v, want 2 forecasts after two failures", len(data), err)
+	}
+	if p.Calls() != 3 {
+		t.Errorf("got %d calls, want 3", p.Calls())
+	}
+
+	// Nakon zadanog broja pokušaja vraća zadnju grešku
+	p = &flakyProvider{failures: 5}
+	if _, err := fetchWithRetry(p, policy, "45.81", "15.98", start, start.Add(time.Hour)); err == nil {
+		t.Fatal("expected an error after all attempts failed")
+	}
+	if p.Calls() != policy.Attempts {
+		t.Errorf("got %d calls, want %d", p.Calls(), policy.Attempts)
+	}
+}
@@ -2,10 +2,8 @@ package api
 
 import (
 	"database/sql"
-	"errors"
 	"fmt"
 	"log"
-	"os"
 	"strings"
 	"time"
 
@@ -17,85 +15,49 @@ import (
 
 var db *sql.DB
 
-// Ove varijable treba exportirati u sistemsko okruženje
-// sa njima pripadajućim vrijednostima
-const (
-	dbUser = "DBUSER"
-	dbPass = "DBPASS"
-	dbHost = "DBHOST"
-	dbName = "DBNAME"
-	dbPort = "DBPORT"
-)
-
-// InitializeDb incijalizira konekciju na bazi
-func InitializeDb() {
-
-	// Poziv dbConfig funkcije da dohvati potrebne varijable
-	config := dbConfig()
-	var err error
+// forecastColumns su stupci tablice forecasts redom kojim ih dodajemo
+var forecastColumns = []string{
+	"location_id", "icon", "forecast_time", "rain", "snow", "temperature", "humidity", "wind_speed",
+	"sources", "temperature_min", "temperature_max", "temperature_agreement",
+	"wind_speed_min", "wind_speed_max", "wind_speed_agreement",
+	"precipitation_mean", "precipitation_min", "precipitation_max", "precipitation_agreement",
+	"agreement", "resolution",
+	"wind_deg", "wind_gust", "pressure", "clouds", "visibility", "feels_like", "pop",
+	"condition_id", "icon_code", "wmo_code", "condition", "provider",
+}
 
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s "+
-		"password=%s dbname=%s sslmode=disable",
-		config[dbHost], config[dbPort],
-		config[dbUser], config[dbPass], config[dbName])
+// InitializeDb incijalizira konekciju na bazi.
+// Podatke za konekciju priprema paket config.
+func InitializeDb(psqlInfo string) (err error) {
 
 	db, err = sql.Open("postgres", psqlInfo)
 	if err != nil {
 		log.Print("Ne može se uspostaviti konkcija prema bazi")
-		panic(err)
+		return err
 	}
 
 	// Provjeravamo da li postoji konekcija
 	err = db.Ping()
 	if err != nil {
 		log.Print("Ne može se uspostaviti konkcija prema bazi")
-		panic(err)
+		return err
 	}
 	fmt.Println("Uspješno spojeno na bazu!")
-	return
+	return nil
 }
 
-// Ova funkcija preuzima sistemske varijable i vraća ih u map conf varijabli
-func dbConfig() map[string]string {
-	conf := make(map[string]string)
-	host, err := os.LookupEnv(dbHost)
-	if !err {
-		log.Print("DBHOST environment variable nije podešena!")
-		panic("DBHOST environment variable nije podešena!")
-	}
-	port, err := os.LookupEnv(dbPort)
-	if !err {
-		log.Print()
-		panic("DBPORT environment variable nije podešena!")
-	}
-	user, err := os.LookupEnv(dbUser)
-	if !err {
-		log.Print("DBUSER environment variable nije podešena!")
-		panic("DBUSER environment variable nije podešena!")
-	}
-	password, err := os.LookupEnv(dbPass)
-	if !err {
-		log.Print("DBPASS environment variable nije podešena!")
-		panic("DBPASS environment variable nije podešena!")
-	}
-	name, err := os.LookupEnv(dbName)
-	if !err {
-		log.Print("DBNAME environment variable nije podešena!")
-		panic("DBNAME environment variable nije podešena!")
-	}
-	conf[dbHost] = host
-	conf[dbPort] = port
-	conf[dbUser] = user
-	conf[dbPass] = password
-	conf[dbName] = name
-	return conf
-}
+// GetWeather dohvaća sve prognoze za utrku koja ima određeni id, poredane po vremenu.
+// Interval utrke se može suziti s from i to, a nulto vrijeme znači da granica nije zadana.
+func GetWeather(id int, from, to time.Time) (data RaceForecast, err error) {
 
-// GetWeather dohvaća određene prognoze za utrku koja ima određeni id
-func GetWeather(id int) (data WeatherData, err error) {
+	data.Race, err = GetRace(int64(id))
+	if err != nil {
+		return data, err
+	}
 
 	// Ovom naredvom vratiti ćemo samo
-	// prognoze koje nisu prošle.
+	// prognoze koje nisu prošle. Dnevne prognoze vraćamo
+	// ako se njihov dan preklapa s intervalom.
 	sqlStr := `WITH race AS (
 					SELECT location_id,
 					race_start,
@@ -104,76 +66,236 @@ func GetWeather(id int) (data WeatherData, err error) {
 					races 
 				WHERE race_id=$1)
 
-				SELECT icon, forecast_time, rain, snow, temperature, humidity, wind_speed
+				SELECT icon, forecast_time, rain, snow, temperature, humidity, wind_speed,
+					sources, temperature_min, temperature_max, temperature_agreement,
+					wind_speed_min, wind_speed_max, wind_speed_agreement,
+					precipitation_mean, precipitation_min, precipitation_max, precipitation_agreement,
+					agreement, resolution,
+					wind_deg, wind_gust, pressure, clouds, visibility, feels_like, pop,
+					condition_id, icon_code, wmo_code, condition,
+					provider, fetched_at
 				FROM
 					forecasts
 				WHERE location_id = (SELECT location_id FROM race) 
 				AND
-					forecast_time >= (SELECT race_start FROM race)
-				AND 
-					forecast_time <= (SELECT race_end FROM race)
-				AND
-					forecast_time >= CURRENT_TIMESTAMP`
+					forecast_in_race(forecast_time, resolution,
+						GREATEST((SELECT race_start FROM race), CURRENT_TIMESTAMP, $2::timestamptz),
+						LEAST((SELECT race_end FROM race), $3::timestamptz))
+				ORDER BY forecast_time`
 
-	// Dohvaćamo prognozu iz baze koji odgovaraju,
-	// u suprotnom vraćamo grešku
-	err = db.QueryRow(sqlStr, id).Scan(&data.WeatherIcon,
-		&data.Date,
-		&data.Rain,
-		&data.Snow,
-		&data.Temp,
-		&data.Humidity,
-		&data.WindSpeed)
+	rows, err := db.Query(sqlStr, id, nullTime(from), nullTime(to))
+	if err != nil {
+		return data, InternalError("greška pri dohvaćanju podataka", err)
+	}
+	defer rows.Close()
 
-	// Ovisno o postojanju ili nepostojanju greške
-	// vračamo odgovarajući odgovor
-	switch err {
-	case sql.ErrNoRows:
-		log.Println("nepostojeći id", err)
-		err = errors.New("nepostojeći id ili je zadana utrka prošla")
-		return data, err
-	case nil:
-		return data, err
-	default:
-		log.Println("greška pri dohvaćanju podataka", err)
-		err = errors.New("greška pri dohvaćanju podataka")
-		return data, err
+	// Vremena prognoza vraćamo u vremenskoj zoni utrke, a za izvor
+	// i vrijeme dohvata uzimamo onaj redak koji je zadnji dohvaćen
+	loc := raceLocation(data.Race.TimeZone)
+	var fetchedAt time.Time
+	data.Forecasts = []WeatherData{}
+	for rows.Next() {
+		var row WeatherData
+		var ens ensembleRow
+		var provider string
+		var forecastTime, rowFetchedAt time.Time
+		err = rows.Scan(&row.WeatherIcon,
+			&forecastTime,
+			&row.Rain,
+			&row.Snow,
+			&row.Temp,
+			&row.Humidity,
+			&row.WindSpeed,
+			&ens.sources,
+			&ens.values[0], &ens.values[1], &ens.values[2],
+			&ens.values[3], &ens.values[4], &ens.values[5],
+			&ens.values[6], &ens.values[7], &ens.values[8], &ens.values[9],
+			&ens.values[10],
+			&row.Resolution,
+			&row.WindDeg,
+			&row.WindGust,
+			&row.Pressure,
+			&row.Clouds,
+			&row.Visibility,
+			&row.FeelsLike,
+			&row.Pop,
+			&row.ConditionID,
+			&row.IconCode,
+			&row.WMOCode,
+			&row.Condition,
+			&provider,
+			&rowFetchedAt)
+		if err != nil {
+			return data, InternalError("greška pri dohvaćanju podataka", err)
+		}
+		row.Date = forecastTime.In(loc).Format(time.RFC3339)
+		row.Ensemble = ens.stats(row)
+		if rowFetchedAt.After(fetchedAt) {
+			fetchedAt = rowFetchedAt
+			data.Provider = provider
+		}
+		data.Forecasts = append(data.Forecasts, row)
+	}
+	if err = rows.Err(); err != nil {
+		return data, InternalError("greška pri dohvaćanju podataka", err)
 	}
+	if !fetchedAt.IsZero() {
+		data.FetchedAt = fetchedAt.In(loc).Format(time.RFC3339)
+	}
+
+	return data, nil
 }
 
-// GetAllRaces dohvaća sve utrke iz baze.
-func GetAllRaces() (races []Race, err error) {
+// nullTime vraća NULL za nulto vrijeme, a inače vrijeme u UTC-u
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
 
-	sqlStr := `SELECT 
-					race_id,
-					name,
-					race_start,
-					race_end,
-					locations.lat,
-					locations.lon 
+// QueryRaces dohvaća utrke koje odgovaraju filterima, poredane i
+// ograničene na zadanu stranicu. Vraća i ukupan broj utrka koje
+// odgovaraju filterima, bez obzira na stranicu.
+func QueryRaces(q RaceQuery) (races []Race, total int, err error) {
+
+	var where []string
+	var args []interface{}
+	// arg dodaje argument upita i vraća njegovo mjesto, npr. $3
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if !q.StartsAfter.IsZero() {
+		where = append(where, "race_start >= "+arg(q.StartsAfter.UTC()))
+	}
+	if !q.EndsBefore.IsZero() {
+		where = append(where, "race_end <= "+arg(q.EndsBefore.UTC()))
+	}
+	switch q.Status {
+	case "upcoming":
+		where = append(where, "race_end > CURRENT_TIMESTAMP")
+	case "finished":
+		where = append(where, "race_end <= CURRENT_TIMESTAMP")
+	}
+	if q.Name != "" {
+		// Znakove % i _ u nazivu tražimo doslovno
+		name := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(q.Name)
+		where = append(where, "name ILIKE '%' || "+arg(name)+" || '%'")
+	}
+	if b := q.BBox; b != nil {
+		where = append(where, "lat BETWEEN "+arg(b.MinLat)+" AND "+arg(b.MaxLat))
+		if b.MinLon <= b.MaxLon {
+			where = append(where, "lon BETWEEN "+arg(b.MinLon)+" AND "+arg(b.MaxLon))
+		} else {
+			// Područje prelazi 180. meridijan
+			where = append(where, "(lon >= "+arg(b.MinLon)+" OR lon <= "+arg(b.MaxLon)+")")
+		}
+	}
+
+	sqlStr := `SELECT ` + raceColumns + `,
+					count(*) OVER ()
 			   FROM 
 					races  
 				NATURAL INNER JOIN 
 					locations`
+	if len(where) > 0 {
+		sqlStr += " WHERE " + strings.Join(where, " AND ")
+	}
+	sqlStr += " ORDER BY " + raceOrder[q.Sort]
+	if q.Limit > 0 {
+		sqlStr += " LIMIT " + arg(q.Limit)
+	}
+	if q.Offset > 0 {
+		sqlStr += " OFFSET " + arg(q.Offset)
+	}
 
-	rows, err := db.Query(sqlStr)
+	rows, err := db.Query(sqlStr, args...)
 	if err != nil {
-		log.Println(err)
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var row Race
 	for rows.Next() {
-		err = rows.Scan(&row.ID, &row.Name, &row.Begin, &row.End, &row.Lat, &row.Lon)
+		row, err := scanRace(rows, &total)
 		if err != nil {
-			log.Println(err)
-			return races, err
+			return races, total, err
 		}
 		races = append(races, row)
 	}
+	if err := rows.Err(); err != nil {
+		return races, total, err
+	}
 
-	return races, err
+	// Ako je stranica iza zadnje utrke nemamo ni ukupan broj,
+	// pa ga dohvaćamo bez stranice.
+	if len(races) == 0 && q.Offset > 0 {
+		_, total, err = QueryRaces(RaceQuery{
+			StartsAfter: q.StartsAfter,
+			EndsBefore:  q.EndsBefore,
+			Status:      q.Status,
+			Name:        q.Name,
+			BBox:        q.BBox,
+			Limit:       1,
+		})
+	}
+	return races, total, err
+}
+
+// raceOrder su dopušteni poretci utrka. Utrke s istim početkom
+// ili nazivom poredane su po id-u kako bi stranice bile stabilne.
+var raceOrder = map[string]string{
+	"":       "race_id",
+	"start":  "race_start, race_id",
+	"-start": "race_start DESC, race_id DESC",
+	"name":   "name, race_id",
+	"-name":  "name DESC, race_id DESC",
+}
+
+// raceColumns su stupci utrke redom kojim ih čita scanRace
+const raceColumns = `race_id,
+					name,
+					race_start,
+					race_end,
+					time_zone,
+					locations.zone,
+					locations.lat,
+					locations.lon`
+
+// rowScanner je zajedničko sučelje za sql.Row i sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRace čita utrku iz retka s raceColumns stupcima i eventualnim
+// dodatnim stupcima u extra. Zona utrke je ona zadana pri dodavanju
+// utrke, a ako nije zadana zona lokacije. Vrijeme početka i kraja
+// vraća u vremenskoj zoni utrke.
+func scanRace(row rowScanner, extra ...interface{}) (race Race, err error) {
+	var start, end time.Time
+	var zone string
+	dest := []interface{}{&race.ID, &race.Name, &start, &end, &race.TimeZone, &zone, &race.Lat, &race.Lon}
+	err = row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return race, err
+	}
+	race.RaceZone = race.TimeZone
+	if race.TimeZone == "" {
+		race.TimeZone = zone
+	}
+	loc := raceLocation(race.TimeZone)
+	race.Begin = start.In(loc).Format(time.RFC3339)
+	race.End = end.In(loc).Format(time.RFC3339)
+	return race, nil
+}
+
+// raceLocation vraća vremensku zonu utrke, a UTC ako zona nije zadana
+func raceLocation(name string) *time.Location {
+	if loc, err := loadTimeZone(name); err == nil && loc != nil {
+		return loc
+	}
+	return time.UTC
 }
 
 // UpdateWeather ažurira postojeće prognoze
@@ -194,6 +316,20 @@ func UpdateWeather(allData []AllData) (err error) {
 				fmt.Sprintf("%v", element.Humidity),
 				fmt.Sprintf("%v", element.WindSpeed))
 
+			// Prazan string funkcija update_weather sprema kao NULL
+			for _, v := range ensembleValues(element.Ensemble) {
+				if v == nil {
+					elem = append(elem, "")
+				} else {
+					elem = append(elem, fmt.Sprintf("%v", v))
+				}
+			}
+			elem = append(elem, element.Resolution)
+			for _, v := range detailValues(element) {
+				elem = append(elem, fmt.Sprintf("%v", v))
+			}
+			elem = append(elem, data.Provider)
+
 			listData = append(listData, elem)
 		}
 		i++
@@ -209,24 +345,111 @@ func UpdateWeather(allData []AllData) (err error) {
 }
 
 // CreateRace dodaje nove utrku u bazu
-func CreateRace(name, lat, lon string, raceStart, raceEnd time.Time) (raceID, locID int64, err error) {
+func CreateRace(name, lat, lon, timeZone string, raceStart, raceEnd time.Time) (raceID, locID int64, err error) {
+	raceID, locID, err = createRace(db, NewRace{name, lat, lon, timeZone, raceStart, raceEnd})
+	if e, ok := err.(*Error); ok {
+		return 0, 0, e
+	}
+	if err != nil {
+		return 0, 0, InternalError("greška pri dodavanju utrke", err)
+	}
+	return raceID, locID, nil
+}
+
+// CreateRaces dodaje sve utrke u jednoj transakciji. Ako dodavanje
+// bilo koje utrke ne uspije, ne dodaje se nijedna. Vraća id-ove
+// utrka i njihovih lokacija istim redom kao utrke.
+func CreateRaces(races []NewRace) (raceIDs, locIDs []int64, err error) {
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, InternalError("greška pri dodavanju utrka", err)
+	}
+	for i, race := range races {
+		raceID, locID, err := createRace(tx, race)
+		if e, ok := err.(*Error); ok {
+			tx.Rollback()
+			return nil, nil, ConflictError("%d. utrka: %s, nijedna utrka nije dodana", i+1, e.Message)
+		}
+		if err != nil {
+			tx.Rollback()
+			return nil, nil, InternalError(fmt.Sprintf("greška pri dodavanju %d. utrke, nijedna utrka nije dodana", i+1), err)
+		}
+		raceIDs = append(raceIDs, raceID)
+		locIDs = append(locIDs, locID)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, InternalError("greška pri dodavanju utrka", err)
+	}
+	return raceIDs, locIDs, nil
+}
+
+// rowQuerier je zajedničko sučelje za sql.DB i sql.Tx
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// raceConflict vraća ConflictError ako već postoji druga utrka istog
+// naziva i početka na istoj lokaciji. Za novu utrku id je 0.
+func raceConflict(q rowQuerier, id int64, race NewRace) error {
+
+	sqlStr := `SELECT
+					race_id
+				FROM
+					races
+				NATURAL INNER JOIN
+					locations
+				WHERE
+					name = $1 AND lat = $2 AND lon = $3 AND race_start = $4 AND race_id <> $5
+				LIMIT 1`
+
+	var other int64
+	err := q.QueryRow(sqlStr, race.Name, race.Lat, race.Lon, race.Start.UTC(), id).Scan(&other)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return ConflictError("utrka %q s istim početkom na istoj lokaciji već postoji pod id-om %d", race.Name, other)
+}
+
+// createRace dodaje utrku preko funkcije create_race. Ako ista
+// utrka već postoji vraća ConflictError, a ostale greške baze
+// vraća bez promjene.
+func createRace(q rowQuerier, race NewRace) (raceID, locID int64, err error) {
+
+	if err = raceConflict(q, 0, race); err != nil {
+		return 0, 0, err
+	}
 
 	// Ovdje koristimo funkciju za dodavanje nove utrke.
 	// Ona provjera da li postoji lokacija nove utrke u bazi.
-	sqlStr := `SELECT create_race($1, $2, $3, $4, $5)`
+	// Vremensku zonu lokacije šaljemo za slučaj da lokacija još ne postoji.
+	sqlStr := `SELECT create_race($1, $2, $3, $4, $5, $6, $7)`
 
 	var twoID []sql.NullInt64
-	err = db.QueryRow(sqlStr, name, lat, lon, raceStart, raceEnd).Scan(pq.Array(&twoID))
+	err = q.QueryRow(sqlStr, race.Name, race.Lat, race.Lon, race.Start.UTC(), race.End.UTC(), race.TimeZone,
+		locationZone(race.Lat, race.Lon)).Scan(pq.Array(&twoID))
+	if err != nil {
+		return 0, 0, err
+	}
 
-	return twoID[0].Int64, twoID[1].Int64, err
+	return twoID[0].Int64, twoID[1].Int64, nil
 }
 
-// InsertWeatherPodcast za zadanu utrku ubacuje prognoze u bazu
-func InsertWeatherPodcast(data []WeatherData, locID int64) (err error) {
+// InsertWeatherPodcast za zadanu utrku ubacuje prognoze u bazu,
+// a uz njih sprema i naziv izvora koji ih je dao.
+func InsertWeatherPodcast(data []WeatherData, locID int64, provider string) (err error) {
+
+	// Ako nema prognoza nemamo što ni dodati
+	if len(data) == 0 {
+		return nil
+	}
 
 	// Priprema SQL naredbe za insert
 	sqlStr := `INSERT INTO 
-					forecasts
+					forecasts (` + strings.Join(forecastColumns, ", ") + `)
 				VALUES`
 
 	// Incijalizacija varijable koja će biti
@@ -235,12 +458,16 @@ func InsertWeatherPodcast(data []WeatherData, locID int64) (err error) {
 	// pa nije potrebna dodatna prvojera.
 	vals := []interface{}{}
 	l := len(data)
+	n := len(forecastColumns)
 	for i := 0; i < l; i++ {
 
 		// Dodajemo potrebne argumente u SQL naredbu
 		// za kasnije učitavanje podataka
-		sqlStr += fmt.Sprintf(" ($%v, $%v, $%v, $%v, $%v, $%v, $%v, $%v),",
-			i*8+1, i*8+2, i*8+3, i*8+4, i*8+5, i*8+6, i*8+7, i*8+8)
+		placeholders := make([]string, n)
+		for j := range placeholders {
+			placeholders[j] = fmt.Sprintf("$%v", i*n+j+1)
+		}
+		sqlStr += " (" + strings.Join(placeholders, ", ") + "),"
 
 		// Učitavamo podatke u listu
 		vals = append(vals,
@@ -252,6 +479,10 @@ func InsertWeatherPodcast(data []WeatherData, locID int64) (err error) {
 			data[i].Temp,
 			data[i].Humidity,
 			data[i].WindSpeed)
+		vals = append(vals, ensembleValues(data[i].Ensemble)...)
+		vals = append(vals, data[i].Resolution)
+		vals = append(vals, detailValues(data[i])...)
+		vals = append(vals, provider)
 	}
 
 	// Uklanjamo posljednji zarez iz naredbe
@@ -269,13 +500,7 @@ func InsertWeatherPodcast(data []WeatherData, locID int64) (err error) {
 func GetRace(id int64) (data Race, err error) {
 	// Ovom naredvom vratiti ćemo samo
 	// prognoze koje nisu prošle.
-	sqlStr := `SELECT 
-					race_id,
-					name,
-					race_start,
-					race_end,
-					locations.lat,
-					locations.lon 
+	sqlStr := `SELECT ` + raceColumns + `
 			   FROM 
 					races  
 				NATURAL INNER JOIN 
@@ -285,21 +510,17 @@ func GetRace(id int64) (data Race, err error) {
 
 	// Dohvaćamo retke iz baze koji odgovaraju,
 	// u suprotnom vraćamo grešku
-	err = db.QueryRow(sqlStr, id).Scan(&data.ID, &data.Name, &data.Begin, &data.End, &data.Lat, &data.Lon)
+	data, err = scanRace(db.QueryRow(sqlStr, id))
 
 	// Ovisno o postojanju ili nepostojanju greške
 	// vračamo odgovarajući odgovor
 	switch err {
 	case sql.ErrNoRows:
-		log.Println("nepostojeći id", err)
-		err = errors.New("nepostojeći id")
-		return data, err
+		return data, NotFoundError("utrka s id %d ne postoji", id)
 	case nil:
-		return data, err
+		return data, nil
 	default:
-		log.Println("greška pri dohvaćanju podataka", err)
-		err = errors.New("greška pri dohvaćanju podataka")
-		return data, err
+		return data, InternalError("greška pri dohvaćanju podataka", err)
 	}
 }
 
@@ -317,30 +538,38 @@ func DeleteRace(id int) (err error) {
 	// Ovisno o postojanju ili nepostojanju greške
 	// vračamo odgovarajući odgovor
 	if err != nil {
-		log.Println("problem pri brisanju utrke", err)
-		err = errors.New("problem pri brisanju utrke")
-		return err
+		return InternalError("problem pri brisanju utrke", err)
 	}
 
 	// Provjeravamo da li je Id utrke bio važeći.
 	if !find {
-		log.Println("Nepostojeći id!", err)
-		err = errors.New("nepostojeći id")
-		return err
+		return NotFoundError("utrka s id %d ne postoji", id)
 	}
 
 	return nil
 }
 
+// RaceRenamed vraća update_race kada su se promijenili samo naziv ili
+// zona utrke. Negativan je kako se ne bi miješao s id-om lokacije.
+const RaceRenamed = -1
+
 // UpdateRace ažurira podataka o utrci
-func UpdateRace(id int64, name, lat, lon string, start, end time.Time) (returnValue int64, err error) {
+func UpdateRace(id int64, name, lat, lon, timeZone string, start, end time.Time) (returnValue int64, err error) {
 
-	sqlStr := `SELECT update_race($1, $2, $3, $4, $5, $6)`
+	err = raceConflict(db, id, NewRace{name, lat, lon, timeZone, start, end})
+	if e, ok := err.(*Error); ok {
+		return 0, e
+	}
+	if err != nil {
+		return 0, InternalError("greška pri ažuriranju utrke", err)
+	}
 
-	err = db.QueryRow(sqlStr, id, name, start, end, lat, lon).Scan(&returnValue)
+	sqlStr := `SELECT update_race($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	err = db.QueryRow(sqlStr, id, name, start.UTC(), end.UTC(), lat, lon, timeZone,
+		locationZone(lat, lon)).Scan(&returnValue)
 	if err != nil {
-		log.Println(err)
-		return 0, err
+		return 0, InternalError("greška pri ažuriranju utrke", err)
 	}
 	return returnValue, err
 }
@@ -349,7 +578,13 @@ func UpdateRace(id int64, name, lat, lon string, start, end time.Time) (returnVa
 func GetNotFinishedRaces() (races []NotFinishedRace, err error) {
 
 	sqlStr := `SELECT 
-					*
+					location_id,
+					race_id,
+					name,
+					race_start,
+					race_end,
+					lat,
+					lon
 				FROM 
 					races  
 				NATURAL INNER JOIN 
@@ -384,10 +619,13 @@ func GetNotFinishedRaces() (races []NotFinishedRace, err error) {
 func DeleteWeatherPodcast() {
 
 	// Naredba za dohvaćanje id-a utrka koje imaju prognozu, a prošle su.
+	// Dnevnu prognozu brišemo tek kad prođe cijeli dan.
 	sqlStr1 := `DELETE FROM 
 							forecasts
 						WHERE 
-							forecast_time < CURRENT_TIMESTAMP`
+							forecast_time < CURRENT_TIMESTAMP
+						AND
+							(resolution <> '1d' OR forecast_time + interval '12 hours' < CURRENT_TIMESTAMP)`
 
 	// Izvršavanje upita nad bazom, ako je neuspješan vraćamo grešku.
 	rows, err := db.Query(sqlStr1)
@@ -431,3 +669,141 @@ func DeleteWeatherPodcast() {
 	}
 	return
 }
+
+// ensembleValues vraća vrijednosti stupaca za prognozu spojenu iz više izvora
+// redom kojim su navedeni u forecastColumns. Za obične prognoze vraća NULL vrijednosti.
+func ensembleValues(e *EnsembleStats) []interface{} {
+	if e == nil {
+		return make([]interface{}, 12)
+	}
+	return []interface{}{
+		strings.Join(e.Sources, ","),
+		e.Temp.Min, e.Temp.Max, e.Temp.Agreement,
+		e.WindSpeed.Min, e.WindSpeed.Max, e.WindSpeed.Agreement,
+		e.Precipitation.Mean, e.Precipitation.Min, e.Precipitation.Max, e.Precipitation.Agreement,
+		e.Agreement,
+	}
+}
+
+// detailValues vraća vrijednosti stupaca s detaljima prognoze,
+// redom kojim su navedeni u forecastColumns.
+func detailValues(d WeatherData) []interface{} {
+	return []interface{}{d.WindDeg, d.WindGust, d.Pressure, d.Clouds, d.Visibility, d.FeelsLike, d.Pop,
+		d.ConditionID, d.IconCode, d.WMOCode, d.Condition}
+}
+
+// ensembleRow služi za čitanje stupaca prognoze spojene iz više izvora
+type ensembleRow struct {
+	sources sql.NullString
+	values  [11]sql.NullFloat64
+}
+
+// stats vraća EnsembleStats ili nil ako prognoza nije spojena iz više izvora.
+// Srednje vrijednosti temperature i vjetra su u stupcima same prognoze.
+func (r ensembleRow) stats(data WeatherData) *EnsembleStats {
+	if !r.sources.Valid {
+		return nil
+	}
+	v := r.values
+	return &EnsembleStats{
+		Sources:       strings.Split(r.sources.String, ","),
+		Temp:          Spread{data.Temp, v[0].Float64, v[1].Float64, v[2].Float64},
+		WindSpeed:     Spread{data.WindSpeed, v[3].Float64, v[4].Float64, v[5].Float64},
+		Precipitation: Spread{v[6].Float64, v[7].Float64, v[8].Float64, v[9].Float64},
+		Agreement:     v[10].Float64,
+	}
+}
+
+// retrySelect je upit za lokacije u redu za ponovni pokušaj
+const retrySelect = `SELECT
+					retry_queue.location_id,
+					locations.lat,
+					locations.lon,
+					attempts,
+					last_error,
+					next_attempt
+				FROM
+					retry_queue
+				NATURAL INNER JOIN
+					locations`
+
+// GetDueRetries dohvaća lokacije iz reda kojima je došlo vrijeme za
+// ponovni pokušaj, a na kojima još postoje utrke koje nisu završile.
+func GetDueRetries() (entries []RetryEntry, err error) {
+	return queryRetries(retrySelect + `
+				WHERE
+					next_attempt <= CURRENT_TIMESTAMP
+				AND
+					EXISTS (SELECT * FROM races WHERE races.location_id = retry_queue.location_id
+												AND race_end > CURRENT_TIMESTAMP)`)
+}
+
+// GetWaitingRetries dohvaća id-eve lokacija iz reda za ponovni pokušaj
+// kojima još nije došlo vrijeme za idući pokušaj
+func GetWaitingRetries() (locIDs map[int]bool, err error) {
+	rows, err := db.Query(`SELECT location_id FROM retry_queue WHERE next_attempt > CURRENT_TIMESTAMP`)
+	if err != nil {
+		log.Println(err)
+		return locIDs, err
+	}
+	defer rows.Close()
+
+	locIDs = make(map[int]bool)
+	var locID int
+	for rows.Next() {
+		if err = rows.Scan(&locID); err != nil {
+			log.Println(err)
+			return locIDs, err
+		}
+		locIDs[locID] = true
+	}
+	return locIDs, rows.Err()
+}
+
+// GetRetries dohvaća sve lokacije iz reda za ponovni pokušaj
+func GetRetries() (entries []RetryEntry, err error) {
+	return queryRetries(retrySelect + ` ORDER BY next_attempt`)
+}
+
+func queryRetries(sqlStr string) (entries []RetryEntry, err error) {
+	rows, err := db.Query(sqlStr)
+	if err != nil {
+		log.Println(err)
+		return entries, err
+	}
+	defer rows.Close()
+
+	var row RetryEntry
+	for rows.Next() {
+		err = rows.Scan(&row.LocID, &row.Lat, &row.Lon, &row.Attempts, &row.LastError, &row.NextAttempt)
+		if err != nil {
+			log.Println(err)
+			return entries, err
+		}
+		entries = append(entries, row)
+	}
+	return entries, rows.Err()
+}
+
+// EnqueueRetry sprema lokaciju u red za ponovni pokušaj. Svaki idući
+// neuspjeh udvostručuje vrijeme do idućeg pokušaja, do najviše jednog dana.
+func EnqueueRetry(locID int, cause error) (err error) {
+	sqlStr := `INSERT INTO
+					retry_queue (location_id, attempts, last_error, next_attempt)
+				VALUES
+					($1, 1, $2, CURRENT_TIMESTAMP + interval '15 minutes')
+				ON CONFLICT (location_id) DO UPDATE SET
+					attempts = retry_queue.attempts + 1,
+					last_error = $2,
+					next_attempt = CURRENT_TIMESTAMP +
+						LEAST(interval '15 minutes' * power(2, retry_queue.attempts), interval '24 hours')`
+
+	_, err = db.Exec(sqlStr, locID, fmt.Sprint(cause))
+	return err
+}
+
+// DeleteRetry uklanja lokaciju iz reda za ponovni pokušaj
+func DeleteRetry(locID int) (err error) {
+	_, err = db.Exec(`DELETE FROM retry_queue WHERE location_id = $1`, locID)
+	return err
+}
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tolerancije razlike između izvora iznad kojih smatramo
+// da se izvori uopće ne slažu (ocjena slaganja je 0).
+const (
+	tempTolerance          = 5.0 // °C
+	windSpeedTolerance     = 5.0 // m/s
+	precipitationTolerance = 5.0 // mm za razmak spojene prognoze
+)
+
+// Ensemble je izvor prognoze koji za iste vremenske trenutke
+// spaja prognoze više izvora u jednu prognozu. Uz srednju vrijednost
+// vraća raspon izvora i ocjenu koliko se izvori slažu.
+type Ensemble struct {
+	Providers []WeatherProvider
+	// MinSources je najmanji broj izvora koji moraju imati prognozu
+	// za neki trenutak da bi ta prognoza bila uključena.
+	MinSources int
+}
+
+// NewEnsemble kreira novi izvor prognoze koji spaja zadane izvore
+func NewEnsemble(providers ...WeatherProvider) *Ensemble {
+	return &Ensemble{
+		Providers:  providers,
+		MinSources: 2,
+	}
+}
+
+// Name vraća naziv izvora prognoze
+func (e *Ensemble) Name() string {
+	names := make([]string, len(e.Providers))
+	for i, p := range e.Providers {
+		names[i] = p.Name()
+	}
+	return "ensemble(" + strings.Join(names, ",") + ")"
+}
+
+// Forecast istovremeno dohvaća prognoze sa svih izvora i spaja ih
+// po vremenu prognoze. Ako neki izvor ne uspije, prognoza se radi
+// od ostalih izvora, a greška se vraća samo ako nijedan izvor nije uspio.
+func (e *Ensemble) Forecast(ctx context.Context, lat, lon string, start, end time.Time) (data []WeatherData, err error) {
+
+	results := make([][]WeatherData, len(e.Providers))
+	errs := make([]error, len(e.Providers))
+
+	var wg sync.WaitGroup
+	for i, p := range e.Providers {
+		wg.Add(1)
+		go func(i int, p WeatherProvider) {
+			defer wg.Done()
+			results[i], errs[i] = p.Forecast(ctx, lat, lon, start, end)
+		}(i, p)
+	}
+	wg.Wait()
+
+	// Grupiramo prognoze svih izvora po vremenu prognoze
+	byTime := make(map[int64][]ensembleSample)
+	succeeded := 0
+	var failures []string
+	for i, p := range e.Providers {
+		if errs[i] != nil {
+			log.Printf("Izvor %s nije uspio dohvatiti prognozu: %v", p.Name(), errs[i])
+			failures = append(failures, fmt.Sprintf("%s: %v", p.Name(), errs[i]))
+			continue
+		}
+		succeeded++
+		for _, d := range results[i] {
+			t, err := time.Parse(time.RFC3339, d.Date)
+			if err != nil {
+				return []WeatherData{}, err
+			}
+			byTime[t.Unix()] = append(byTime[t.Unix()], ensembleSample{p.Name(), d})
+		}
+	}
+	if succeeded == 0 {
+		return []WeatherData{}, errors.New("nijedan izvor nije uspio: " + strings.Join(failures, "; "))
+	}
+
+	// Ako je uspjelo manje izvora nego što tražimo, uzimamo sve što imamo
+	minSources := e.MinSources
+	if minSources > succeeded {
+		minSources = succeeded
+	}
+
+	times := make([]int64, 0, len(byTime))
+	for t := range byTime {
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	data = []WeatherData{}
+	for _, t := range times {
+		samples := byTime[t]
+		if len(samples) < minSources {
+			continue
+		}
+		data = append(data, blend(time.Unix(t, 0).UTC(), samples))
+	}
+	return data, nil
+}
+
+// ensembleSample je prognoza jednog izvora za jedan vremenski trenutak
+type ensembleSample struct {
+	source string
+	data   WeatherData
+}
+
+// blend spaja prognoze više izvora za isti trenutak u jednu prognozu.
+// Izvori daju oborine za različite razmake (npr. 1h i 6h), pa ih prije
+// spajanja pretvaramo u mm/h, a spojene vraćamo za razmak spojene prognoze.
+func blend(t time.Time, samples []ensembleSample) WeatherData {
+
+	// Spojena prognoza nije preciznija od najgrubljeg izvora
+	resolution := samples[0].data.Resolution
+	for _, s := range samples {
+		if resolutionOrder[s.data.Resolution] > resolutionOrder[resolution] {
+			resolution = s.data.Resolution
+		}
+	}
+	hours := resolutionHours(resolution)
+
+	var temps, winds, precips, rains, snows, humidities []float64
+	var gusts, pressures, clouds, visibilities, feelsLikes, pops, degs []float64
+	var sources []string
+	codes := make(map[int]int)
+	for _, s := range samples {
+		sources = append(sources, s.source)
+		temps = append(temps, s.data.Temp)
+		winds = append(winds, s.data.WindSpeed)
+		// Oborine izvora za razmak spojene prognoze
+		scale := hours / resolutionHours(s.data.Resolution)
+		precips = append(precips, (s.data.Rain+s.data.Snow)*scale)
+		rains = append(rains, s.data.Rain*scale)
+		snows = append(snows, s.data.Snow*scale)
+		humidities = append(humidities, float64(s.data.Humidity))
+		gusts = append(gusts, s.data.WindGust)
+		pressures = append(pressures, s.data.Pressure)
+		clouds = append(clouds, float64(s.data.Clouds))
+		feelsLikes = append(feelsLikes, s.data.FeelsLike)
+		pops = append(pops, s.data.Pop)
+		degs = append(degs, float64(s.data.WindDeg))
+		// Izvori koji ne daju vidljivost ne ulaze u prosjek
+		if s.data.Visibility > 0 {
+			visibilities = append(visibilities, float64(s.data.Visibility))
+		}
+		codes[s.data.WMOCode]++
+	}
+
+	stats := &EnsembleStats{
+		Sources:       sources,
+		Temp:          newSpread(temps, tempTolerance),
+		WindSpeed:     newSpread(winds, windSpeedTolerance),
+		Precipitation: newSpread(precips, precipitationTolerance),
+	}
+	stats.Agreement = round2((stats.Temp.Agreement + stats.WindSpeed.Agreement + stats.Precipitation.Agreement) / 3)
+
+	// Za vrijeme uzimamo WMO kod koji se najčešće pojavljuje, a kod
+	// jednakog broja prednost ima izvor koji je prvi naveden. Opis, kod
+	// i ikonu izvora preuzimamo od prvog izvora s tim WMO kodom.
+	condition := samples[0].data
+	for _, s := range samples {
+		if codes[s.data.WMOCode] > codes[condition.WMOCode] {
+			condition = s.data
+		}
+	}
+
+	return WeatherData{
+		Resolution:  resolution,
+		Date:        t.Format(time.RFC3339),
+		Temp:        stats.Temp.Mean,
+		Humidity:    int(mean(humidities) + 0.5),
+		WeatherIcon: condition.WeatherIcon,
+		ConditionID: condition.ConditionID,
+		IconCode:    condition.IconCode,
+		WMOCode:     condition.WMOCode,
+		Condition:   condition.Condition,
+		WindSpeed:   stats.WindSpeed.Mean,
+		Rain:        round2(mean(rains)),
+		Snow:        round2(mean(snows)),
+		WindDeg:     meanDirection(degs),
+		WindGust:    round2(mean(gusts)),
+		Pressure:    round2(mean(pressures)),
+		Clouds:      int(mean(clouds) + 0.5),
+		Visibility:  int(mean(visibilities) + 0.5),
+		FeelsLike:   round2(mean(feelsLikes)),
+		Pop:         round2(mean(pops)),
+		Ensemble:    stats,
+	}
+}
+
+// resolutionOrder poredava razmake prognoza od najfinijeg prema najgrubljem
+var resolutionOrder = map[string]int{
+	Resolution1h: 1,
+	Resolution3h: 2,
+	Resolution6h: 3,
+	Resolution1d: 4,
+}
+
+// resolutionHours vraća broj sati za koje prognoza daje oborine.
+// Prognoze bez razmaka su starije prognoze u koracima od 3 sata.
+func resolutionHours(resolution string) float64 {
+	switch resolution {
+	case Resolution1h:
+		return 1
+	case Resolution6h:
+		return 6
+	case Resolution1d:
+		return 24
+	default:
+		return 3
+	}
+}
+
+// newSpread računa srednju vrijednost, raspon i ocjenu slaganja izvora.
+// Ocjena je 1 kada svi izvori daju istu vrijednost, a 0 kada je
+// razlika najveće i najmanje vrijednosti jednaka toleranciji ili veća.
+func newSpread(values []float64, tolerance float64) Spread {
+	min, max := values[0], values[0]
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	agreement := math.Max(0, 1-(max-min)/tolerance)
+	return Spread{
+		Mean:      round2(mean(values)),
+		Min:       min,
+		Max:       max,
+		Agreement: round2(agreement),
+	}
+}
+
+// meanDirection računa srednji smjer vjetra preko vektora,
+// kako bi npr. prosjek od 350° i 10° bio 0°, a ne 180°.
+func meanDirection(degs []float64) int {
+	var x, y float64
+	for _, d := range degs {
+		x += math.Cos(d * math.Pi / 180)
+		y += math.Sin(d * math.Pi / 180)
+	}
+	deg := math.Atan2(y, x) * 180 / math.Pi
+	if deg < 0 {
+		deg += 360
+	}
+	return int(deg+0.5) % 360
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
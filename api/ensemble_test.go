@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBlend(t *testing.T) {
+	at := time.Date(2030, 5, 4, 12, 0, 0, 0, time.UTC)
+	samples := []ensembleSample{
+		{"prvi", WeatherData{Temp: 10, WindSpeed: 2, Rain: 1, Humidity: 60, WeatherIcon: "kiša prvog izvora",
+			WMOCode: 61, Condition: ConditionRain, WindDeg: 350, Pressure: 1010, Visibility: 10000,
+			Resolution: Resolution1h}},
+		{"drugi", WeatherData{Temp: 14, WindSpeed: 4, Rain: 3, Snow: 3, Humidity: 81, WeatherIcon: "vedro",
+			WMOCode: 0, Condition: ConditionClear, WindDeg: 10, Pressure: 1014,
+			Resolution: Resolution6h}},
+		{"treći", WeatherData{Temp: 12, WindSpeed: 3, Humidity: 70, WeatherIcon: "kiša",
+			WMOCode: 61, Condition: ConditionRain, WindDeg: 0, Pressure: 1012, Visibility: 20000}},
+	}
+	d := blend(at, samples)
+
+	// Spojena prognoza ima razmak najgrubljeg izvora
+	if !mustParseTime(t, d.Date).Equal(at) || d.Resolution != Resolution6h {
+		t.Errorf("unexpected date or resolution %+v", d)
+	}
+	if d.Temp != 12 || d.WindSpeed != 3 || d.Humidity != 70 {
+		t.Errorf("unexpected means %+v", d)
+	}
+	// Smjer vjetra je prosjek vektora, a vidljivost samo izvora koji je daju
+	if d.WindDeg != 0 || d.Pressure != 1012 || d.Visibility != 15000 {
+		t.Errorf("unexpected wind direction, pressure or visibility %+v", d)
+	}
+	// Vrijeme je najčešći WMO kod, s opisom prvog izvora koji ga ima
+	if d.WMOCode != 61 || d.Condition != ConditionRain || d.WeatherIcon != "kiša prvog izvora" {
+		t.Errorf("unexpected condition %+v", d)
+	}
+
+	stats := d.Ensemble
+	if len(stats.Sources) != 3 || stats.Temp.Min != 10 || stats.Temp.Max != 14 {
+		t.Errorf("unexpected ensemble stats %+v", stats)
+	}
+
+	// Oborine su za šest sati: satna kiša od 1 mm je 6 mm, a prognoza
+	// bez razmaka je za tri sata, pa je njena kiša od 0 mm i dalje 0 mm
+	if d.Rain != 3 || d.Snow != 1 {
+		t.Errorf("rain = %v, snow = %v, want 3 and 1", d.Rain, d.Snow)
+	}
+	p := stats.Precipitation
+	if p.Min != 0 || p.Max != 6 || p.Mean != 4 || p.Agreement != 0 {
+		t.Errorf("unexpected precipitation spread %+v", p)
+	}
+	// Temperatura se razlikuje za 4 °C, a brzina vjetra za 2 m/s
+	if stats.Temp.Agreement != 0.2 || stats.WindSpeed.Agreement != 0.6 || stats.Agreement != 0.27 {
+		t.Errorf("unexpected agreement %+v", stats)
+	}
+}
+
+func TestEnsembleForecast(t *testing.T) {
+	start := time.Date(2030, 5, 4, 9, 0, 0, 0, time.UTC)
+	good := &fakeProvider{name: "prvi"}
+	also := &fakeProvider{name: "drugi"}
+	bad := &fakeProvider{name: "treći", err: errors.New("nedostupan")}
+
+	e := NewEnsemble(good, also, bad)
+	data, err := e.Forecast(context.Background(), "45.81", "15.98", start, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 3 {
+		t.Fatalf("got %d forecasts, want 3", len(data))
+	}
+	for _, d := range data {
+		if d.Ensemble == nil || len(d.Ensemble.Sources) != 2 || d.Ensemble.Agreement != 1 {
+			t.Errorf("unexpected ensemble stats %+v", d.Ensemble)
+		}
+	}
+	if e.Name() != "ensemble(prvi,drugi,treći)" {
+		t.Errorf("name = %q", e.Name())
+	}
+
+	// Ako uspije samo jedan izvor, vraćamo njegove prognoze
+	also.err = errors.New("nedostupan")
+	if data, err = e.Forecast(context.Background(), "45.81", "15.98", start, start.Add(2*time.Hour)); err != nil || len(data) != 3 {
+		t.Errorf("got %d forecasts and %v, want 3 forecasts from one provider", len(data), err)
+	}
+
+	good.err = errors.New("nedostupan")
+	if _, err = e.Forecast(context.Background(), "45.81", "15.98", start, start.Add(2*time.Hour)); err == nil {
+		t.Error("expected an error when no provider succeeds")
+	}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorKind je vrsta greške domene, a o njoj ovisi HTTP status odgovora
+type ErrorKind int
+
+// Vrste grešaka domene
+const (
+	KindInternal ErrorKind = iota
+	KindNotFound
+	KindValidation
+	KindConflict
+	KindUpstream
+)
+
+// errorKinds su HTTP status i kod za svaku vrstu greške
+var errorKinds = map[ErrorKind]struct {
+	status int
+	code   string
+}{
+	KindInternal:   {http.StatusInternalServerError, "internal"},
+	KindNotFound:   {http.StatusNotFound, "not_found"},
+	KindValidation: {http.StatusBadRequest, "validation"},
+	KindConflict:   {http.StatusConflict, "conflict"},
+	KindUpstream:   {http.StatusBadGateway, "upstream"},
+}
+
+// Error je greška domene. Message je poruka za klijenta, a Err uzrok
+// greške koji samo zapisujemo u log, kako klijent ne bi vidio npr.
+// poruke baze podataka.
+type Error struct {
+	Kind    ErrorKind
+	Message string
+	Fields  ValidationErrors
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// NotFoundError je greška za nepostojeći resurs
+func NotFoundError(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+// ValidationError je greška u podacima zahtjeva, s greškom za svako polje
+func ValidationError(message string, fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Message: message, Fields: fields}
+}
+
+// ConflictError je greška za zahtjev koji je u sukobu s postojećim podacima
+func ConflictError(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+// UpstreamError je greška izvora prognoze
+func UpstreamError(message string, err error) *Error {
+	return &Error{Kind: KindUpstream, Message: message, Err: err}
+}
+
+// InternalError je greška servisa, npr. baze podataka
+func InternalError(message string, err error) *Error {
+	return &Error{Kind: KindInternal, Message: message, Err: err}
+}
+
+// asError pretvara bilo koju grešku u grešku domene. Greške
+// koje nisu greške domene smatramo greškama servisa.
+func asError(err error) *Error {
+	switch e := err.(type) {
+	case *Error:
+		return e
+	case ValidationErrors:
+		return ValidationError("podaci nisu ispravni", e...)
+	default:
+		return InternalError("greška servisa", err)
+	}
+}
+
+// Problem je tijelo odgovora s greškom prema RFC 7807.
+// Code i Errors su dodatna polja s vrstom greške i greškama polja.
+type Problem struct {
+	Type     string           `json:"type"`
+	Title    string           `json:"title"`
+	Status   int              `json:"status"`
+	Detail   string           `json:"detail,omitempty"`
+	Instance string           `json:"instance,omitempty"`
+	Code     string           `json:"code"`
+	Errors   ValidationErrors `json:"errors,omitempty"`
+}
+
+// MIMEProblemJSON je Content-Type odgovora s greškom
+const MIMEProblemJSON = "application/problem+json"
+
+// respondError šalje grešku kao application/problem+json odgovor
+// sa statusom koji odgovara vrsti greške.
+func respondError(c *gin.Context, err error) {
+	problem := problemFor(c, err)
+	body, _ := json.Marshal(problem)
+	c.Data(problem.Status, MIMEProblemJSON, body)
+}
+
+// problemFor pretvara grešku u Problem i zapisuje u log greške
+// servisa i izvora prognoze, čiji uzrok klijent ne vidi.
+func problemFor(c *gin.Context, err error) *Problem {
+	e := asError(err)
+	kind := errorKinds[e.Kind]
+	if e.Kind == KindInternal || e.Kind == KindUpstream {
+		log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, e)
+	}
+
+	return &Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(kind.status),
+		Status:   kind.status,
+		Detail:   e.Message,
+		Instance: c.Request.URL.Path,
+		Code:     kind.code,
+		Errors:   e.Fields,
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRespondError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{NotFoundError("utrka %d ne postoji", 7), http.StatusNotFound, "not_found", "utrka 7 ne postoji"},
+		{ValidationErrors{{Field: "name", Code: CodeRequired}}, http.StatusBadRequest, "validation", "podaci nisu ispravni"},
+		{ConflictError("utrka već postoji"), http.StatusConflict, "conflict", "utrka već postoji"},
+		{UpstreamError("izvor prognoze nije dostupan", errors.New("timeout")), http.StatusBadGateway, "upstream", "izvor prognoze nije dostupan"},
+		{errors.New("pq: connection refused"), http.StatusInternalServerError, "internal", "greška servisa"},
+	}
+	for _, tt := range tests {
+		c, w := newTestContext("/race/7")
+		respondError(c, tt.err)
+
+		if w.Code != tt.status {
+			t.Errorf("%v: status %d, want %d", tt.err, w.Code, tt.status)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, MIMEProblemJSON) {
+			t.Errorf("%v: Content-Type %q", tt.err, ct)
+		}
+		var p Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+		// Poruke baze ne smiju doći do klijenta
+		if p.Status != tt.status || p.Code != tt.code || p.Detail != tt.detail || p.Instance != "/race/7" {
+			t.Errorf("%v: got %+v", tt.err, p)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stanja prekidača strujnog kruga
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// ErrBreakerOpen vraća izvor čiji je prekidač otvoren
+var ErrBreakerOpen = errors.New("prekidač je otvoren")
+
+// CircuitBreaker je prekidač strujnog kruga za jedan izvor prognoze.
+// Nakon Threshold uzastopnih grešaka prekidač se otvara i izvor se
+// ne poziva sve dok ne prođe Cooldown. Tada prekidač prelazi u poluotvoreno
+// stanje i propušta jedan probni poziv. Ako on uspije prekidač se zatvara,
+// a ako ne uspije ponovno se otvara.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	openedAt  time.Time
+	lastError string
+	// probing je true dok traje probni poziv u poluotvorenom stanju
+	probing bool
+}
+
+// NewCircuitBreaker kreira novi zatvoreni prekidač
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+// Allow provjerava smije li se izvor pozvati
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.Cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		// U poluotvorenom stanju propuštamo samo jedan probni poziv
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Success bilježi uspješan poziv i zatvara prekidač
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure bilježi neuspješan poziv i po potrebi otvara prekidač
+func (b *CircuitBreaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.lastError = fmt.Sprint(err)
+	b.probing = false
+	if b.state == BreakerHalfOpen || b.failures >= b.Threshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Abort bilježi poziv koji je prekinut prije rezultata, npr. zbog
+// prekinutog ctx. Ne broji se kao greška, ali oslobađa probni poziv
+// kako bi idući poziv u poluotvorenom stanju ponovno mogao probati izvor.
+func (b *CircuitBreaker) Abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// Status vraća trenutno stanje prekidača
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		State:     b.state,
+		Failures:  b.failures,
+		LastError: b.lastError,
+	}
+	if b.state != BreakerClosed {
+		status.OpenedAt = b.openedAt.Format(time.RFC3339)
+		status.RetryAt = b.openedAt.Add(b.Cooldown).Format(time.RFC3339)
+	}
+	return status
+}
+
+// Failover je izvor prognoze koji redom pokušava izvore iz liste.
+// Ako jedan izvor ne uspije, ili mu je prekidač otvoren, prelazi na idući.
+type Failover struct {
+	Providers []WeatherProvider
+	Breakers  []*CircuitBreaker
+}
+
+// NewFailover kreira novi lanac izvora, svaki sa svojim prekidačem
+func NewFailover(providers ...WeatherProvider) *Failover {
+	f := &Failover{Providers: providers}
+	for range providers {
+		f.Breakers = append(f.Breakers, NewCircuitBreaker(3, 5*time.Minute))
+	}
+	return f
+}
+
+// Name vraća naziv izvora prognoze
+func (f *Failover) Name() string {
+	names := make([]string, len(f.Providers))
+	for i, p := range f.Providers {
+		names[i] = p.Name()
+	}
+	return "failover(" + strings.Join(names, ",") + ")"
+}
+
+// Forecast vraća prognozu prvog izvora koji uspije
+func (f *Failover) Forecast(ctx context.Context, lat, lon string, start, end time.Time) (data []WeatherData, err error) {
+
+	var failures []string
+	for i, p := range f.Providers {
+		breaker := f.Breakers[i]
+		if !breaker.Allow() {
+			failures = append(failures, fmt.Sprintf("%s: %v", p.Name(), ErrBreakerOpen))
+			continue
+		}
+
+		data, err = p.Forecast(ctx, lat, lon, start, end)
+		// Ako je poziv prekinut, izvor nije kriv i ne pokušavamo dalje
+		if ctx.Err() != nil {
+			breaker.Abort()
+			return []WeatherData{}, ctx.Err()
+		}
+		if err != nil {
+			log.Printf("Izvor %s nije uspio, prelazimo na idući. Greska:%v", p.Name(), err)
+			breaker.Failure(err)
+			failures = append(failures, fmt.Sprintf("%s: %v", p.Name(), err))
+			continue
+		}
+		breaker.Success()
+		return data, nil
+	}
+
+	return []WeatherData{}, errors.New("nijedan izvor nije uspio: " + strings.Join(failures, "; "))
+}
+
+// BreakerStatuses vraća stanje prekidača za svaki izvor u lancu
+func (f *Failover) BreakerStatuses() []BreakerStatus {
+	var statuses []BreakerStatus
+	for i, p := range f.Providers {
+		status := f.Breakers[i].Status()
+		status.Provider = p.Name()
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// BreakerStatuses vraća stanje prekidača svih lanaca izvora u ensemble-u
+func (e *Ensemble) BreakerStatuses() []BreakerStatus {
+	return breakerStatuses(e.Providers...)
+}
+
+// breakerReporter zadovoljavaju izvori koji imaju prekidače
+type breakerReporter interface {
+	BreakerStatuses() []BreakerStatus
+}
+
+// breakerStatuses skuplja stanja prekidača zadanih izvora
+func breakerStatuses(providers ...WeatherProvider) []BreakerStatus {
+	statuses := []BreakerStatus{}
+	for _, p := range providers {
+		if r, ok := p.(breakerReporter); ok {
+			statuses = append(statuses, r.BreakerStatuses()...)
+		}
+	}
+	return statuses
+}
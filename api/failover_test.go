@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := NewCircuitBreaker(2, time.Hour)
+	if !b.Allow() {
+		t.Fatal("closed breaker must allow calls")
+	}
+
+	b.Failure(errors.New("prva"))
+	if b.Status().State != BreakerClosed || !b.Allow() {
+		t.Fatal("breaker opened before reaching the threshold")
+	}
+	b.Failure(errors.New("druga"))
+	status := b.Status()
+	if status.State != BreakerOpen || status.Failures != 2 || status.LastError != "druga" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if b.Allow() {
+		t.Fatal("open breaker allowed a call before the cooldown")
+	}
+
+	// Nakon isteka odgode propušta samo jedan probni poziv
+	b.Cooldown = 0
+	if !b.Allow() {
+		t.Fatal("breaker did not allow a probe after the cooldown")
+	}
+	if b.Status().State != BreakerHalfOpen || b.Allow() {
+		t.Fatal("half-open breaker must allow only one probe")
+	}
+
+	// Neuspjela proba ponovno otvara prekidač, a uspješna ga zatvara
+	b.Failure(errors.New("treća"))
+	if b.Status().State != BreakerOpen {
+		t.Fatal("failed probe did not open the breaker")
+	}
+	if !b.Allow() {
+		t.Fatal("breaker did not allow a second probe")
+	}
+	b.Success()
+	if status := b.Status(); status.State != BreakerClosed || status.Failures != 0 {
+		t.Fatalf("successful probe did not close the breaker: %+v", status)
+	}
+}
+
+func TestCircuitBreakerAbort(t *testing.T) {
+	b := NewCircuitBreaker(1, 0)
+	b.Failure(errors.New("greška"))
+	if !b.Allow() {
+		t.Fatal("breaker did not allow a probe")
+	}
+
+	// Prekinuta proba ne mijenja stanje, ali oslobađa probni poziv
+	b.Abort()
+	if status := b.Status(); status.State != BreakerHalfOpen || status.Failures != 1 {
+		t.Fatalf("abort changed the breaker state: %+v", status)
+	}
+	if !b.Allow() {
+		t.Fatal("aborted probe was not released")
+	}
+}
+
+func TestFailover(t *testing.T) {
+	first := &fakeProvider{name: "prvi", err: errors.New("nedostupan")}
+	second := &fakeProvider{name: "drugi"}
+	f := NewFailover(first, second)
+	f.Breakers[0].Threshold = 2
+
+	start := time.Date(2030, 5, 4, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		data, err := f.Forecast(context.Background(), "45.81", "15.98", start, start.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+		if len(data) != 2 {
+			t.Fatalf("call %d: got %d forecasts, want 2", i+1, len(data))
+		}
+	}
+
+	// Nakon dvije greške prvi izvor se više ne poziva
+	if first.Calls() != 2 || second.Calls() != 3 {
+		t.Errorf("got %d and %d calls, want 2 and 3", first.Calls(), second.Calls())
+	}
+	statuses := f.BreakerStatuses()
+	if statuses[0].Provider != "prvi" || statuses[0].State != BreakerOpen || statuses[1].State != BreakerClosed {
+		t.Errorf("unexpected breaker statuses %+v", statuses)
+	}
+
+	second.err = errors.New("također nedostupan")
+	if _, err := f.Forecast(context.Background(), "45.81", "15.98", start, start.Add(time.Hour)); err == nil {
+		t.Fatal("expected an error when no provider succeeds")
+	}
+}
+
+func TestFailoverCancelledProbe(t *testing.T) {
+	p := &fakeProvider{release: make(chan struct{})}
+	f := NewFailover(p)
+	f.Breakers[0].Threshold = 1
+	f.Breakers[0].Cooldown = 0
+	f.Breakers[0].Failure(errors.New("greška"))
+
+	// Probni poziv prekidamo prije nego što izvor odgovori
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Date(2030, 5, 4, 9, 0, 0, 0, time.UTC)
+	if _, err := f.Forecast(ctx, "45.81", "15.98", start, start.Add(time.Hour)); err != context.Canceled {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+
+	// Idući poziv mora ponovno probati izvor
+	close(p.release)
+	if _, err := f.Forecast(context.Background(), "45.81", "15.98", start, start.Add(time.Hour)); err != nil {
+		t.Fatalf("provider was not probed again: %v", err)
+	}
+	if p.Calls() != 2 {
+		t.Errorf("got %d calls, want 2", p.Calls())
+	}
+	if state := f.Breakers[0].Status().State; state != BreakerClosed {
+		t.Errorf("state = %s, want %s", state, BreakerClosed)
+	}
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Udaljenosti računamo na kugli, bez PostGIS-a, pa pretraga radi
+// na postojećoj shemi. Baza samo suzi izbor na pravokutno područje
+// oko tražene točke, a točnu udaljenost i pripadnost mnogokutu
+// provjeravamo ovdje.
+
+// EarthRadiusKm je srednji polumjer Zemlje u kilometrima
+const EarthRadiusKm = 6371.0
+
+// MaxRadiusKm je najveći polumjer pretrage, pola opsega Zemlje
+const MaxRadiusKm = math.Pi * EarthRadiusKm
+
+// Point je točka zadana geografskom širinom i dužinom u stupnjevima
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// Polygon je mnogokut zadan vrhovima, bez ponavljanja prvog vrha na kraju.
+// Stranice su ravne crte u stupnjevima širine i dužine, pa mnogokut
+// ne smije prelaziti 180. meridijan.
+type Polygon []Point
+
+// Area je područje pretrage: pravokutnik i, ako je zadan, mnogokut unutar njega
+type Area struct {
+	BBox    *BBox
+	Polygon Polygon
+}
+
+// haversineKm vraća udaljenost dviju točaka po velikoj kružnici u kilometrima
+func haversineKm(a, b Point) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// radiusBBox vraća najmanji pravokutnik koji sadrži sve točke
+// udaljene najviše km kilometara od točke p
+func radiusBBox(p Point, km float64) *BBox {
+	r := km / EarthRadiusKm
+	dLat := r * 180 / math.Pi
+	b := &BBox{MinLat: p.Lat - dLat, MaxLat: p.Lat + dLat, MinLon: -180, MaxLon: 180}
+
+	// Ako krug sadrži pol, sadrži i sve dužine
+	if b.MinLat <= -90 || b.MaxLat >= 90 {
+		b.MinLat = math.Max(b.MinLat, -90)
+		b.MaxLat = math.Min(b.MaxLat, 90)
+		return b
+	}
+
+	s := math.Sin(r) / math.Cos(p.Lat*math.Pi/180)
+	if s >= 1 {
+		return b
+	}
+	dLon := math.Asin(s) * 180 / math.Pi
+	b.MinLon = normalizeLon(p.Lon - dLon)
+	b.MaxLon = normalizeLon(p.Lon + dLon)
+	return b
+}
+
+// normalizeLon vraća geografsku dužinu u rasponu od -180 do 180
+func normalizeLon(lon float64) float64 {
+	for lon < -180 {
+		lon += 360
+	}
+	for lon > 180 {
+		lon -= 360
+	}
+	return lon
+}
+
+// Contains javlja da li je točka unutar pravokutnika
+func (b *BBox) Contains(p Point) bool {
+	if p.Lat < b.MinLat || p.Lat > b.MaxLat {
+		return false
+	}
+	if b.MinLon <= b.MaxLon {
+		return p.Lon >= b.MinLon && p.Lon <= b.MaxLon
+	}
+	// Pravokutnik prelazi 180. meridijan
+	return p.Lon >= b.MinLon || p.Lon <= b.MaxLon
+}
+
+// Center vraća središte pravokutnika
+func (b *BBox) Center() Point {
+	lon := (b.MinLon + b.MaxLon) / 2
+	if b.MinLon > b.MaxLon {
+		lon = normalizeLon(lon + 180)
+	}
+	return Point{Lat: (b.MinLat + b.MaxLat) / 2, Lon: lon}
+}
+
+// Contains javlja da li je točka unutar mnogokuta. Točke na
+// stranicama mogu, ovisno o zaokruživanju, biti unutra ili izvan.
+func (poly Polygon) Contains(p Point) bool {
+	inside := false
+	for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+		a, b := poly[i], poly[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lon < (b.Lon-a.Lon)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// BBox vraća najmanji pravokutnik koji sadrži mnogokut
+func (poly Polygon) BBox() *BBox {
+	b := &BBox{MinLat: 90, MaxLat: -90, MinLon: 180, MaxLon: -180}
+	for _, p := range poly {
+		b.MinLat = math.Min(b.MinLat, p.Lat)
+		b.MaxLat = math.Max(b.MaxLat, p.Lat)
+		b.MinLon = math.Min(b.MinLon, p.Lon)
+		b.MaxLon = math.Max(b.MaxLon, p.Lon)
+	}
+	return b
+}
+
+// Contains javlja da li je točka unutar područja
+func (a Area) Contains(p Point) bool {
+	if a.BBox != nil && !a.BBox.Contains(p) {
+		return false
+	}
+	if len(a.Polygon) > 0 && !a.Polygon.Contains(p) {
+		return false
+	}
+	return true
+}
+
+// bbox vraća pravokutnik u kojem tražimo utrke u blizini. Bez područja
+// i polumjera pretraga nije ograničena, pa pravokutnik nije potreban.
+func (q NearbyQuery) bbox() *BBox {
+	if q.Area != nil {
+		return q.Area.BBox
+	}
+	if q.RadiusKm > 0 {
+		return radiusBBox(q.Point, q.RadiusKm)
+	}
+	return nil
+}
+
+// parsePoint čita točku iz teksta geografske širine i dužine
+func parsePoint(lat, lon string) (Point, error) {
+	var p Point
+	var err error
+	if p.Lat, err = parseDecimal(strings.TrimSpace(lat)); err != nil {
+		return p, fmt.Errorf("%q nije broj", lat)
+	}
+	if p.Lon, err = parseDecimal(strings.TrimSpace(lon)); err != nil {
+		return p, fmt.Errorf("%q nije broj", lon)
+	}
+	if p.Lat < -90 || p.Lat > 90 || p.Lon < -180 || p.Lon > 180 {
+		return p, errors.New("geografska širina mora biti između -90 i 90, a dužina između -180 i 180")
+	}
+	return p, nil
+}
+
+// parsePolygon čita mnogokut zadan kao lon,lat,lon,lat,lon,lat... Redoslijed
+// dužine i širine je isti kao u bbox parametru i GeoJSON-u, a vrhovi nisu
+// odvojeni s ; jer ga net/url ne prihvaća u parametrima upita.
+func parsePolygon(value string) (Polygon, error) {
+	parts := strings.Split(strings.TrimSpace(value), ",")
+	if len(parts)%2 != 0 || len(parts) < 6 {
+		return nil, errors.New("mnogokut mora imati barem tri vrha zadana kao lon,lat,lon,lat,lon,lat")
+	}
+
+	poly := make(Polygon, 0, len(parts)/2)
+	for i := 0; i < len(parts); i += 2 {
+		p, err := parsePoint(parts[i+1], parts[i])
+		if err != nil {
+			return nil, err
+		}
+		poly = append(poly, p)
+	}
+
+	// Zatvoreni mnogokut s ponovljenim prvim vrhom prihvaćamo kao i otvoreni
+	if len(poly) > 3 && poly[0] == poly[len(poly)-1] {
+		poly = poly[:len(poly)-1]
+	}
+	return poly, nil
+}
+
+// polygonRule zahtijeva da je polje ispravan mnogokut
+func polygonRule(field, value string) *FieldError {
+	if _, err := parsePolygon(value); err != nil {
+		return &FieldError{field, CodeInvalidPolygon, err.Error()}
+	}
+	return nil
+}
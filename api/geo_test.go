@@ -0,0 +1,153 @@
+package api
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHaversineKm(t *testing.T) {
+	zagreb := Point{45.815, 15.982}
+	split := Point{43.508, 16.440}
+	tests := []struct {
+		a, b Point
+		want float64
+	}{
+		{zagreb, zagreb, 0},
+		{zagreb, split, 259.3},
+		{Point{0, 0}, Point{0, 180}, math.Pi * EarthRadiusKm},
+		{Point{0, 179.5}, Point{0, -179.5}, 111.2},
+		{Point{90, 0}, Point{-90, 0}, math.Pi * EarthRadiusKm},
+	}
+	for _, tt := range tests {
+		if got := haversineKm(tt.a, tt.b); math.Abs(got-tt.want) > 0.5 {
+			t.Errorf("haversineKm(%v, %v) = %.1f, want %.1f", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestRadiusBBox(t *testing.T) {
+	center := Point{45.815, 15.982}
+	b := radiusBBox(center, 100)
+
+	// Točke na udaljenosti od 100 km u svim smjerovima moraju biti unutra
+	for _, p := range []Point{
+		{center.Lat + 0.899, center.Lon},
+		{center.Lat - 0.899, center.Lon},
+		{center.Lat, center.Lon + 1.28},
+		{center.Lat, center.Lon - 1.28},
+	} {
+		if d := haversineKm(center, p); d > 100 {
+			t.Fatalf("test point %v is %.1f km away", p, d)
+		}
+		if !b.Contains(p) {
+			t.Errorf("bbox %+v does not contain %v", b, p)
+		}
+	}
+	if b.Contains(Point{center.Lat + 1, center.Lon}) || b.Contains(Point{center.Lat, center.Lon + 1.5}) {
+		t.Errorf("bbox %+v is too large", b)
+	}
+
+	// Pravokutnik uz 180. meridijan prelazi na drugu stranu
+	b = radiusBBox(Point{0, 179.9}, 50)
+	if b.MinLon < b.MaxLon || !b.Contains(Point{0, -179.9}) || b.Contains(Point{0, 0}) {
+		t.Errorf("unexpected bbox across the antimeridian %+v", b)
+	}
+
+	// Krug oko pola sadrži sve dužine
+	b = radiusBBox(Point{89.9, 0}, 50)
+	if b.MaxLat != 90 || b.MinLon != -180 || b.MaxLon != 180 {
+		t.Errorf("unexpected bbox around the pole %+v", b)
+	}
+}
+
+func TestBBoxCenter(t *testing.T) {
+	b := &BBox{MinLat: -10, MaxLat: 10, MinLon: 170, MaxLon: -170}
+	if c := b.Center(); c.Lat != 0 || c.Lon != 180 {
+		t.Errorf("center = %v, want 0, 180", c)
+	}
+}
+
+func TestPolygonContains(t *testing.T) {
+	// Mnogokut u obliku slova L
+	poly := Polygon{{0, 0}, {0, 10}, {5, 10}, {5, 5}, {10, 5}, {10, 0}}
+	tests := []struct {
+		p    Point
+		want bool
+	}{
+		{Point{2, 2}, true},
+		{Point{2, 8}, true},
+		{Point{8, 2}, true},
+		{Point{8, 8}, false},
+		{Point{-1, 2}, false},
+		{Point{11, 2}, false},
+	}
+	for _, tt := range tests {
+		if got := poly.Contains(tt.p); got != tt.want {
+			t.Errorf("Contains(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+
+	b := poly.BBox()
+	if b.MinLat != 0 || b.MaxLat != 10 || b.MinLon != 0 || b.MaxLon != 10 {
+		t.Errorf("unexpected bbox %+v", b)
+	}
+
+	// Područje sadrži samo točke unutar i pravokutnika i mnogokuta
+	a := Area{BBox: &BBox{MinLat: 0, MaxLat: 10, MinLon: 6, MaxLon: 10}, Polygon: poly}
+	if !a.Contains(Point{2, 8}) || a.Contains(Point{2, 2}) || a.Contains(Point{8, 8}) {
+		t.Errorf("unexpected area membership")
+	}
+}
+
+func TestParsePolygon(t *testing.T) {
+	poly, err := parsePolygon("15,45, 16,45, 16,46, 15,45")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ponovljeni prvi vrh se uklanja, a parovi su lon,lat
+	if len(poly) != 3 || poly[1] != (Point{45, 16}) {
+		t.Errorf("got %v", poly)
+	}
+
+	for _, value := range []string{
+		"",
+		"15,45,16,45",
+		"15,45,16,45,16",
+		"15,45,16,45,16,nije",
+		"15,45,16,45,16,91",
+		"15,45,16,45,16,0x1p4",
+	} {
+		if _, err := parsePolygon(value); err == nil {
+			t.Errorf("parsePolygon(%q) accepted an invalid polygon", value)
+		}
+	}
+}
+
+func TestParsePoint(t *testing.T) {
+	p, err := parsePoint(" 45.815 ", "-15.98")
+	if err != nil || p != (Point{45.815, -15.98}) {
+		t.Errorf("got %v, %v", p, err)
+	}
+	for _, c := range [][2]string{{"91", "0"}, {"0", "-180.5"}, {"NaN", "0"}, {"0", "Inf"}, {"1e1", "0"}} {
+		if _, err := parsePoint(c[0], c[1]); err == nil {
+			t.Errorf("parsePoint(%q, %q) accepted an invalid point", c[0], c[1])
+		}
+	}
+}
+
+func TestNearbyQueryBBox(t *testing.T) {
+	center := Point{45.815, 15.982}
+	if b := (NearbyQuery{Point: center, RadiusKm: 10}).bbox(); b == nil || !b.Contains(center) {
+		t.Errorf("got %+v, want a bbox around the point", b)
+	}
+
+	// Polumjer 0 znači da udaljenost nije ograničena
+	if b := (NearbyQuery{Point: center}).bbox(); b != nil {
+		t.Errorf("got %+v, want no bbox", b)
+	}
+
+	area := &Area{BBox: &BBox{MinLat: 42, MaxLat: 47, MinLon: 13, MaxLon: 20}}
+	if b := (NearbyQuery{Point: center, RadiusKm: 10, Area: area}).bbox(); b != area.BBox {
+		t.Errorf("got %+v, want the area bbox", b)
+	}
+}
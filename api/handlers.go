@@ -2,227 +2,359 @@ package api
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	// Jednostavan i brz HTTP web framework
 	"github.com/gin-gonic/gin"
 )
 
-// GetWeatherHandler funkcija
-func GetWeatherHandler(c *gin.Context) {
+// Handleri API-ja v1. Svi koriste RaceService, kao i API v2,
+// a ovdje samo čitaju zahtjev i oblikuju odgovor kao i do sada.
 
-	// Dohvaćamo id u obliku stringa iz GET zahtjeva
-	idString := c.Param("id")
+// GetWeatherHandler vraća handler koji dohvaća prognoze za utrku
+func GetWeatherHandler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, from, to, err := forecastParams(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
 
-	// Pretvaramo dohvaćeni u int, odmah i
-	// provjeramo potencijalnu grešku.
-	id, err := strconv.Atoi(idString)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"Greska": "ID mora biti cijeli broj!"})
-		log.Print(err)
-		return
+		// Opis vremena vraćamo na jeziku iz parametra lang, npr. ?lang=en
+		data, err := s.Forecast(id, from, to, c.DefaultQuery("lang", DefaultDescriptionLang))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, data)
 	}
+}
 
-	// Proslijeđujemo id funckiji koja dohvača
-	// prognozu ili vraća grešku
-	data, err := GetWeather(id)
-	if err != nil {
-		if fmt.Sprint(err) == "Greška pri dohvaćanju podataka!" {
-			c.JSON(http.StatusInternalServerError, gin.H{"Greska": fmt.Sprint(err)})
+// GetWeatherSummaryHandler vraća handler koji daje
+// sažetak prognoza za cijeli interval utrke
+func GetWeatherSummaryHandler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, from, to, err := forecastParams(c)
+		if err != nil {
+			respondError(c, err)
+			return
 		}
-		c.JSON(http.StatusBadRequest, gin.H{"Greska": fmt.Sprint(err)})
-		log.Print(err)
-		return
-	}
 
-	c.JSON(http.StatusOK, data)
-	return
+		summary, err := s.Summary(id, from, to, c.DefaultQuery("lang", DefaultDescriptionLang))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, summary)
+	}
 }
 
-// GetAllRacesHandler dohvaća sve utrke u bazi.
-func GetAllRacesHandler(c *gin.Context) {
-
-	races, err := GetAllRaces()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"Greska:": "neuspjelo dohvaćanje utrka"})
+// forecastParams čita id utrke iz putanje te parametre from i to,
+// kojima se interval utrke može suziti, u RFC 3339 obliku.
+func forecastParams(c *gin.Context) (id int64, from, to time.Time, err error) {
+	if id, err = paramID(c); err != nil {
 		return
 	}
-	c.JSON(http.StatusOK, races)
+	if from, err = queryTime(c, "from"); err != nil {
+		return
+	}
+	to, err = queryTime(c, "to")
 	return
 }
 
-// GetRaceHandler funkcija
-func GetRaceHandler(c *gin.Context) {
-	// Dohvaćamo id u obliku stringa iz GET zahtjeva
-	idString := c.Param("id")
-
-	// Pretvaramo dohvaćeni u int, odmah i
-	// provjeramo potencijalnu grešku.
-	id, err := strconv.ParseInt(idString, 0, 64)
+// paramID čita id utrke iz putanje zahtjeva
+func paramID(c *gin.Context) (int64, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"Greska": "ID mora biti cijeli broj!"})
-		log.Print(err)
-		return
+		return 0, ValidationError("ID mora biti cijeli broj",
+			FieldError{"id", CodeNotANumber, "id mora biti cijeli broj"})
 	}
+	return id, nil
+}
 
-	// Proslijeđivamo id utrke funkcije za dohvat podataka iz baze.
-	// Ovisno o rezultatu, da li ima greške, vraćamo ili podatke ili grešku.
-	data, err := GetRace(id)
+// queryTime čita vrijeme iz parametra zahtjeva.
+// Ako parametar nije zadan vraća nulto vrijeme.
+func queryTime(c *gin.Context, name string) (t time.Time, err error) {
+	value := c.Query(name)
+	if value == "" {
+		return t, nil
+	}
+	t, err = time.Parse(time.RFC3339, value)
 	if err != nil {
-		if fmt.Sprint(err) == "Greška pri dohvaćanju podataka!" {
-			c.JSON(http.StatusInternalServerError, gin.H{"Greska": fmt.Sprint(err)})
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"Greska": fmt.Sprint(err)})
-		log.Print(err)
-		return
+		message := fmt.Sprintf("%s mora biti vrijeme u RFC 3339 obliku", name)
+		return t, ValidationError(message, FieldError{name, CodeInvalidDateTime, message})
 	}
-	c.JSON(http.StatusOK, data)
-	return
+	return t, nil
 }
 
-// CreateRaceHandler funckija
-func CreateRaceHandler(c *gin.Context) {
+// GetPointForecastHandler vraća handler koji dohvaća prognoze za
+// proizvoljnu točku, bez dodavanja utrke
+func GetPointForecastHandler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, from, to, err := bindPointForecast(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		data, err := s.PointForecast(c.Request.Context(), p, from, to, c.DefaultQuery("lang", DefaultDescriptionLang))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, data)
+	}
+}
 
-	// Dohvat podataka iz POST zahtjeva
-	naziv := c.PostForm("naziv")
-	lat := c.PostForm("lat")
-	lon := c.PostForm("lon")
-	pocetak := c.PostForm("pocetak")
-	kraj := c.PostForm("kraj")
+// BatchForecastHandler vraća handler koji dohvaća prognoze za više točaka
+// odjednom. Odgovor ima rezultat za svaku točku istim redom kao u zahtjevu,
+// s prognozom ili greškom te točke.
+func BatchForecastHandler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		items, err := bindBatchRequest(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
 
-	// Provjeravamo da li su svi zaprimiljeni podatci poslani,
-	// ako nisu vraćamo odgovarajuču grešku.
-	start, end, err := CheckData(naziv, lat, lon, pocetak, kraj)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"Greska:": fmt.Sprint(err)})
-		return
-	}
-	// Pozicanje funkcije za dodavanjem nove utrke
-	raceID, locID, err := CreateRace(naziv, lat, lon, start, end)
-	if err != nil {
-		log.Print(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"Greska": fmt.Sprint(err)})
-		return
+		results := s.BatchForecast(c.Request.Context(), items, c.DefaultQuery("lang", DefaultDescriptionLang))
+		for i, r := range results {
+			if r.Err != nil {
+				results[i].Error = problemFor(c, r.Err)
+			}
+		}
+		c.JSON(http.StatusOK, results)
 	}
-	c.JSON(http.StatusOK, gin.H{"Poruka": "Utrka je uspješno dodana!", "Id_utrke": raceID})
+}
 
-	// Nakon dodavanje nove utrke potrebno
-	// je dodati prognoze za novu utrku u bazu podataka.
-	// Prvo dohvaćamo prognoze vremena sa Open Weather
-	data, err := GetWeatherFromOpenWeather(lat, lon, pocetak, kraj)
-	if err != nil {
-		log.Print(err)
-		fmt.Printf("Greška: %s", fmt.Sprint(err))
-		return
+// GetAllRacesHandler vraća handler koji dohvaća utrke u bazi. Bez parametara
+// vraća sve utrke, a ukupan broj utrka koje odgovaraju filterima je u
+// zaglavlju X-Total-Count.
+func GetAllRacesHandler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q, err := bindRaceQuery(c, 0)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		races, total, err := s.List(q)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.Header("X-Total-Count", strconv.Itoa(total))
+		c.JSON(http.StatusOK, races)
 	}
+}
 
-	// Potom podatke prognoze proslijeđujemo funkciji
-	// koja će ih spremiti u bazu podataka
-	err = InsertWeatherPodcast(data, locID)
-	if err != nil {
-		log.Printf("Greška pri dodavanju prognoza: %v", err)
-		fmt.Printf("Greška pri dodavanju prognoza: %s", err)
-		return
+// NearbyRacesHandler vraća handler koji dohvaća utrke u blizini točke,
+// poredane po udaljenosti
+func NearbyRacesHandler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q, err := bindNearbyQuery(c, 0)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		races, err := s.Nearby(q)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, races)
 	}
-	return
+}
 
+// RacesWithinHandler vraća handler koji dohvaća utrke unutar mnogokuta
+// ili pravokutnika, poredane po udaljenosti
+func RacesWithinHandler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q, err := bindWithinQuery(c, 0)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		races, err := s.Nearby(q)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, races)
+	}
 }
 
-// UpdateRaceHandler funkcija
-func UpdateRaceHandler(c *gin.Context) {
+// ImportRacesHandler vraća handler za uvoz utrka iz CSV datoteke ili JSON
+// niza. Odgovor je izvještaj s rezultatom za svaku utrku, a ako uvoz
+// na načelu sve ili ništa nije uspio zbog neispravnih utrka, status je 400.
+func ImportRacesHandler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reqs, mode, dryRun, err := bindImport(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
 
-	// Dohvat ID utrke koje treba izmijeniti
-	idString := c.Param("id")
+		report, err := s.Import(reqs, mode, dryRun)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(importStatus(report), report)
+	}
+}
 
-	// Pretvaramo dohvaćeni id u int, odmah i
-	// provjeramo potencijalnu grešku.
-	id, err := strconv.ParseInt(idString, 0, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"Greska": "ID mora biti cijeli broj!"})
-		log.Print(err)
-		return
+// importStatus vraća HTTP status odgovora na uvoz utrka
+func importStatus(report ImportReport) int {
+	if report.Mode == ImportAtomic && !report.DryRun && report.Invalid > 0 {
+		return http.StatusBadRequest
 	}
+	return http.StatusOK
+}
 
-	// Dohvat podataka iz PUT body zahtjeva
-	naziv := c.PostForm("naziv")
-	lat := c.PostForm("lat")
-	lon := c.PostForm("lon")
-	pocetak := c.PostForm("pocetak")
-	kraj := c.PostForm("kraj")
+// GetRaceHandler vraća handler koji dohvaća jednu utrku
+func GetRaceHandler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := paramID(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
 
-	// Provjeravamo da li su svi zaprimiljeni podatci poslani,
-	// ako nisu vraćamo odgovarajuču grešku.
-	start, end, err := CheckData(naziv, lat, lon, pocetak, kraj)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, fmt.Sprint(err))
-		return
+		data, err := s.Get(id)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, data)
 	}
+}
 
-	// Pozivanje funkcije za ažuriranje utrke
-	update, err := UpdateRace(id, naziv, lat, lon, start, end)
+// CreateRaceHandler vraća handler za dodavanje nove utrke.
+// Prognoze za novu utrku servis dohvaća nakon odgovora.
+func CreateRaceHandler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
 
-	// Ako postoji greška vraćamo je, ako ne postoji onda
-	// provjeramo treba li ažurirati podatke vezane za prognozu.
-	// Ako ne treba samo izlazimo iz funkcije i vraćamo odgovarajuću poruku.
-	if err != nil {
-		log.Print(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"Greska": err})
-		return
+		// Dohvat podataka iz POST zahtjeva, JSON tijela ili forme
+		req, err := bindRaceRequest(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		raceID, err := s.Create(req)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Poruka": "Utrka je uspješno dodana!", "Id_utrke": raceID})
 	}
-	if update == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"Poruka": "Neuspješno ažuriranje! Nepostojeći id ili su svi podatci isti."})
-		return
+}
+
+// UpdateRaceHandler vraća handler za ažuriranje utrke.
+// Ako treba, prognoze za utrku servis dohvaća nakon odgovora.
+func UpdateRaceHandler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		// Dohvat ID utrke koje treba izmijeniti
+		id, err := paramID(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		// Dohvat podataka iz PUT body zahtjeva, JSON tijela ili forme
+		req, err := bindRaceRequest(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		// Za nepostojeću utrku i utrku s istim podacima vraćamo isti
+		// odgovor kao i prije, a razlikuje ih samo API v2
+		changed, err := s.Update(id, req)
+		if err != nil && asError(err).Kind != KindNotFound {
+			respondError(c, err)
+			return
+		}
+		if !changed {
+			c.JSON(http.StatusBadRequest, gin.H{"Poruka": "Neuspješno ažuriranje! Nepostojeći id ili su svi podatci isti."})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Poruka": "Utrka je uspješno ažurirana!", "Id": id})
 	}
+}
 
-	c.JSON(http.StatusOK, gin.H{"Poruka": "Utrka je uspješno ažurirana!", "Id": id})
-	if update == 1 {
-		return
+// PatchRaceHandler vraća handler za djelomično ažuriranje utrke
+// JSON Merge Patch dokumentom, npr. {"name": "Novi naziv"}
+func PatchRaceHandler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := paramID(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		patch, err := bindRacePatch(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		changed, err := s.Patch(id, patch)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if !changed {
+			c.JSON(http.StatusOK, gin.H{"Poruka": "Utrka nije promijenjena, svi podaci su isti.", "Id": id})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Poruka": "Utrka je uspješno ažurirana!", "Id": id})
 	}
+}
 
-	// Nakon ažuriranje nove utrke potrebno
-	// je ažurirati prognoze.
-	data, err := GetWeatherFromOpenWeather(lat, lon, pocetak, kraj)
-	if err != nil {
-		log.Print(err)
-		fmt.Printf("Greška: %s", err)
-		return
+// DeleteRaceHandler vraća handler koji briše utrku
+func DeleteRaceHandler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := paramID(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		if err := s.Delete(id); err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Odgovor": "Utrka uspješno izbrisana"})
 	}
+}
 
-	// Potom podatke prognoze proslijeđujemo funkciji
-	// koja će ih spremiti u bazu podataka
-	err = InsertWeatherPodcast(data, update)
-	if err != nil {
-		log.Printf("Greška pri ažuriranju prognoza: %v", err)
-		fmt.Printf("Greška pri ažuriranju prognoza: %s", err)
-		return
+// ProviderStatusHandler vraća handler koji prikazuje
+// stanje prekidača za sve izvore prognoze.
+func ProviderStatusHandler(provider WeatherProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, breakerStatuses(provider))
 	}
-	return
 }
 
-// DeleteRaceHandler funkcija
-func DeleteRaceHandler(c *gin.Context) {
-	// Dohvaćamo id u obliku stringa iz GET zahtjeva
-	idString := c.Param("id")
+// RateLimitStatusHandler prikazuje ograničenja poziva
+// prema izvorima prognoze i koliko se na njih čekalo.
+func RateLimitStatusHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, LimiterStatuses())
+}
 
-	// Pretvaramo dohvaćeni u int, odmah i
-	// provjeramo potencijalnu grešku.
-	id, err := strconv.Atoi(idString)
+// RetryQueueHandler prikazuje lokacije za koje automatsko
+// ažuriranje nije uspjelo dohvatiti prognozu.
+func RetryQueueHandler(c *gin.Context) {
+	entries, err := GetRetries()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"Greska": "ID mora biti cijeli broj!"})
-		log.Print(err)
+		respondError(c, InternalError("neuspjelo dohvaćanje reda", err))
 		return
 	}
-
-	// Proslijeđivamo id utrke funkciji za brisanje utrke.
-	// Ako je vraćena greška znači da brisanje nije uspjelo i šaljemo odgovor.
-	err = DeleteRace(id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"Greska": fmt.Sprint(err)})
-		log.Print(err)
-		return
+	if entries == nil {
+		entries = []RetryEntry{}
 	}
-	c.JSON(http.StatusOK, gin.H{"Odgovor": "Utrka uspješno izbrisana"})
-	return
+	c.JSON(http.StatusOK, entries)
 }
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestContext kreira gin kontekst za GET zahtjev na zadani URL
+func newTestContext(url string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, url, nil)
+	return c, w
+}
+
+func TestQueryTime(t *testing.T) {
+	c, _ := newTestContext("/race/1/forecast?from=2030-05-04T10:00:00%2B02:00&to=sutra")
+
+	from, err := queryTime(c, "from")
+	if err != nil || !from.Equal(time.Date(2030, 5, 4, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("from: got %v, %v", from, err)
+	}
+	if _, err := queryTime(c, "to"); err == nil {
+		t.Error("expected an error for a time that is not RFC 3339")
+	}
+
+	// Parametar koji nije zadan daje nulto vrijeme
+	if missing, err := queryTime(c, "until"); err != nil || !missing.IsZero() {
+		t.Errorf("missing parameter: got %v, %v", missing, err)
+	}
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetNoURL je adresa MET Norway Locationforecast 2.0 API-ja. Koristimo
+// complete oblik jer samo on daje udare vjetra i vjerojatnost oborina.
+const MetNoURL = "https://api.met.no/weatherapi/locationforecast/2.0/complete"
+
+// MetNoUserAgent je zadani User-Agent. MET Norway odbija zahtjeve
+// bez User-Agent zaglavlja koje identificira aplikaciju.
+const MetNoUserAgent = "weather_api github.com/leondominovic/weather_api"
+
+// MetNo je izvor prognoze koji koristi MET Norway Locationforecast API.
+// Dohvaćene prognoze pamti po lokaciji i poštuje Expires i Last-Modified
+// zaglavlja, kako ne bi ponovno preuzimali prognoze koje se nisu promijenile.
+type MetNo struct {
+	BaseURL   string
+	UserAgent string
+	Client    *http.Client
+
+	mu    sync.Mutex
+	cache map[Location]*metNoCacheEntry
+}
+
+// metNoCacheEntry je zapamćena prognoza za jednu lokaciju
+type metNoCacheEntry struct {
+	data         MetNoPodcast
+	expires      time.Time
+	lastModified string
+}
+
+// NewMetNo kreira novi MET Norway izvor prognoze
+func NewMetNo(userAgent string) *MetNo {
+	if userAgent == "" {
+		userAgent = MetNoUserAgent
+	}
+	return &MetNo{
+		BaseURL:   MetNoURL,
+		UserAgent: userAgent,
+		Client: &http.Client{
+			Timeout: time.Second * 2,
+		},
+		cache: make(map[Location]*metNoCacheEntry),
+	}
+}
+
+// Name vraća naziv izvora prognoze
+func (mn *MetNo) Name() string {
+	return "metno"
+}
+
+// Forecast dohvaća prognoze za određenu lokaciju,
+// pretvara ih u WeatherData i vraća samo one u intervalu utrke.
+func (mn *MetNo) Forecast(ctx context.Context, lat, lon string, start, end time.Time) (data []WeatherData, err error) {
+
+	filteredForecastData := []WeatherData{}
+
+	// MET Norway traži koordinate s najviše četiri decimale
+	lat, err = truncateCoordinate(lat)
+	if err != nil {
+		return filteredForecastData, err
+	}
+	lon, err = truncateCoordinate(lon)
+	if err != nil {
+		return filteredForecastData, err
+	}
+
+	forecastData, err := mn.fetch(ctx, lat, lon)
+	if err != nil {
+		return filteredForecastData, err
+	}
+
+	for _, ts := range forecastData.Properties.Timeseries {
+		t, err := time.Parse(time.RFC3339, ts.Time)
+		if err != nil {
+			return filteredForecastData, err
+		}
+		t = t.UTC()
+		if !inRaceInterval(t, start, end) {
+			continue
+		}
+
+		details := ts.Data.Instant.Details
+		temp := WeatherData{
+			Date:      t.Format(time.RFC3339),
+			Temp:      details.AirTemperature,
+			Humidity:  int(details.RelativeHumidity + 0.5),
+			Pressure:  details.AirPressure,
+			Clouds:    int(details.CloudAreaFraction + 0.5),
+			WindSpeed: details.WindSpeed,
+			WindDeg:   int(details.WindFromDirection + 0.5),
+			WindGust:  details.WindSpeedOfGust,
+			// MET Norway ne daje osjećaj temperature pa ga računamo
+			FeelsLike: apparentTemperature(details.AirTemperature, details.RelativeHumidity, details.WindSpeed),
+		}
+
+		// Prvih nekoliko dana prognoza je po satu, a kasnije u
+		// koracima od šest sati, pa uzimamo onaj period koji postoji.
+		period := ts.Data.Next1Hours
+		temp.Resolution = Resolution1h
+		if period == nil {
+			period = ts.Data.Next6Hours
+			temp.Resolution = Resolution6h
+		}
+		if period != nil {
+			symbol := metNoSymbol(period.Summary.SymbolCode)
+			temp.IconCode = period.Summary.SymbolCode
+			temp.setCondition(metNoToWMO(symbol))
+			// Vjerojatnost oborina je zadana u postocima
+			temp.Pop = period.Details.ProbabilityOfPrecipitation / 100
+			// Oborine su zadane ukupno pa ih prema simbolu
+			// svrstavamo u snijeg ili kišu.
+			if strings.Contains(symbol, "snow") {
+				temp.Snow = period.Details.PrecipitationAmount
+			} else {
+				temp.Rain = period.Details.PrecipitationAmount
+			}
+		}
+		filteredForecastData = append(filteredForecastData, temp)
+	}
+
+	return filteredForecastData, nil
+}
+
+// fetch vraća prognozu za lokaciju, iz memorije ako još nije istekla,
+// a inače je dohvaća uz If-Modified-Since zaglavlje.
+func (mn *MetNo) fetch(ctx context.Context, lat, lon string) (data MetNoPodcast, err error) {
+
+	key := Location{lat, lon}
+	mn.mu.Lock()
+	cached := mn.cache[key]
+	mn.mu.Unlock()
+
+	if cached != nil && time.Now().Before(cached.expires) {
+		return cached.data, nil
+	}
+
+	url := fmt.Sprintf("%s?lat=%s&lon=%s", mn.BaseURL, lat, lon)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return data, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", mn.UserAgent)
+	if cached != nil && cached.lastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.lastModified)
+	}
+
+	res, err := mn.Client.Do(req)
+	if err != nil {
+		return data, err
+	}
+	defer res.Body.Close()
+
+	expires, _ := http.ParseTime(res.Header.Get("Expires"))
+
+	switch res.StatusCode {
+	case http.StatusNotModified:
+		// Prognoza se nije promijenila, produžujemo samo njeno trajanje
+		if cached == nil {
+			return data, fmt.Errorf("metno: primljen %s bez zapamćene prognoze", res.Status)
+		}
+		mn.mu.Lock()
+		cached.expires = expires
+		mn.mu.Unlock()
+		return cached.data, nil
+	case http.StatusOK:
+		if err := json.NewDecoder(res.Body).Decode(&data); err != nil {
+			return data, err
+		}
+		mn.mu.Lock()
+		mn.cache[key] = &metNoCacheEntry{
+			data:         data,
+			expires:      expires,
+			lastModified: res.Header.Get("Last-Modified"),
+		}
+		mn.mu.Unlock()
+		return data, nil
+	default:
+		return data, fmt.Errorf("metno: neočekivan status %s", res.Status)
+	}
+}
+
+// apparentTemperature računa osjećaj temperature prema Steadmanovoj
+// formuli koja uzima u obzir vlagu i vjetar.
+func apparentTemperature(temp, humidity, windSpeed float64) float64 {
+	// Tlak vodene pare u hPa
+	e := humidity / 100 * 6.105 * math.Exp(17.27*temp/(237.7+temp))
+	return round2(temp + 0.33*e - 0.70*windSpeed - 4.00)
+}
+
+// truncateCoordinate skraćuje koordinatu na četiri decimale
+func truncateCoordinate(coord string) (string, error) {
+	f, err := strconv.ParseFloat(coord, 64)
+	if err != nil {
+		return "", fmt.Errorf("neispravna koordinata: %s", coord)
+	}
+	return strconv.FormatFloat(f, 'f', 4, 64), nil
+}
+
+// metNoSymbol uklanja dodatak _day, _night ili _polartwilight iz simbola
+func metNoSymbol(symbolCode string) string {
+	if i := strings.Index(symbolCode, "_"); i >= 0 {
+		return symbolCode[:i]
+	}
+	return symbolCode
+}
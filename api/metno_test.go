@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// metNoBody je odgovor MET Norway API-ja sa satnom prognozom,
+// prognozom od šest sati i prognozom bez perioda
+const metNoBody = `{"properties": {"timeseries": [
+	{"time": "2030-05-04T08:00:00Z", "data": {"instant": {"details": {"air_temperature": 9}}}},
+	{"time": "2030-05-04T09:00:00Z", "data": {
+		"instant": {"details": {"air_temperature": 12.3, "air_pressure_at_sea_level": 1015.2,
+			"cloud_area_fraction": 62.6, "relative_humidity": 71.4, "wind_speed": 3.1,
+			"wind_from_direction": 224.7, "wind_speed_of_gust": 7.2}},
+		"next_1_hours": {"summary": {"symbol_code": "lightrain_day"},
+			"details": {"precipitation_amount": 0.6, "probability_of_precipitation": 45}},
+		"next_6_hours": {"summary": {"symbol_code": "rain"}, "details": {"precipitation_amount": 4}}}},
+	{"time": "2030-05-04T12:00:00+02:00", "data": {
+		"instant": {"details": {"air_temperature": -1, "relative_humidity": 90}},
+		"next_6_hours": {"summary": {"symbol_code": "heavysnow"},
+			"details": {"precipitation_amount": 5.5, "probability_of_precipitation": 80}}}},
+	{"time": "2030-05-04T18:00:00Z", "data": {"instant": {"details": {"air_temperature": 8}}}}
+]}}`
+
+func TestMetNoForecast(t *testing.T) {
+	srv := newTestServer(http.StatusOK, metNoBody, func(r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("lat") != "45.8150" || q.Get("lon") != "15.9819" {
+			t.Errorf("coordinates not truncated to four decimals: %s", r.URL.RawQuery)
+		}
+		if r.Header.Get("User-Agent") != "test-agent" {
+			t.Errorf("User-Agent = %q", r.Header.Get("User-Agent"))
+		}
+	})
+	defer srv.Close()
+	mn := NewMetNo("test-agent")
+	mn.BaseURL = srv.URL
+
+	start := time.Date(2030, 5, 4, 9, 0, 0, 0, time.UTC)
+	data, err := mn.Forecast(context.Background(), "45.815012", "15.98193", start, start.Add(6*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 {
+		t.Fatalf("got %d forecasts, want 2", len(data))
+	}
+
+	d := data[0]
+	if d.Date != "2030-05-04T09:00:00Z" || d.Resolution != Resolution1h {
+		t.Errorf("unexpected date or resolution %+v", d)
+	}
+	if d.Temp != 12.3 || d.Humidity != 71 || d.Pressure != 1015.2 || d.Clouds != 63 {
+		t.Errorf("unexpected temperature data %+v", d)
+	}
+	if d.WindSpeed != 3.1 || d.WindDeg != 225 || d.WindGust != 7.2 {
+		t.Errorf("unexpected wind data %+v", d)
+	}
+	if d.Rain != 0.6 || d.Snow != 0 || d.Pop != 0.45 {
+		t.Errorf("unexpected precipitation data %+v", d)
+	}
+	if d.IconCode != "lightrain_day" || d.WMOCode != 61 || d.Condition != ConditionRain || d.WeatherIcon != "slaba kiša" {
+		t.Errorf("unexpected condition %+v", d)
+	}
+	if d.FeelsLike != apparentTemperature(12.3, 71.4, 3.1) {
+		t.Errorf("feels like = %v", d.FeelsLike)
+	}
+
+	// Vrijeme s pomakom vraćamo u UTC-u, bez satnog perioda koristimo
+	// šestsatni, a oborine uz snijeg su snijeg
+	d = data[1]
+	if d.Date != "2030-05-04T10:00:00Z" || d.Resolution != Resolution6h {
+		t.Errorf("unexpected date or resolution %+v", d)
+	}
+	if d.Snow != 5.5 || d.Rain != 0 || d.Pop != 0.8 || d.Condition != ConditionSnow {
+		t.Errorf("unexpected snow forecast %+v", d)
+	}
+}
+
+func TestMetNoNotModified(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		mu.Unlock()
+
+		// Prognoza je odmah istekla, pa idući poziv ponovno pita poslužitelj
+		w.Header().Set("Expires", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+		w.Header().Set("Last-Modified", "Sat, 04 May 2030 08:00:00 GMT")
+		if n > 1 {
+			if r.Header.Get("If-Modified-Since") != "Sat, 04 May 2030 08:00:00 GMT" {
+				t.Errorf("If-Modified-Since = %q", r.Header.Get("If-Modified-Since"))
+			}
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, metNoBody)
+	}))
+	defer srv.Close()
+	mn := NewMetNo("")
+	mn.BaseURL = srv.URL
+
+	start := time.Date(2030, 5, 4, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		data, err := mn.Forecast(context.Background(), "45.815", "15.982", start, start.Add(24*time.Hour))
+		if err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+		if len(data) != 4 {
+			t.Fatalf("call %d: got %d forecasts, want 4", i+1, len(data))
+		}
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+}
+
+func TestMetNoStatusError(t *testing.T) {
+	srv := newTestServer(http.StatusForbidden, `{}`, nil)
+	defer srv.Close()
+	mn := NewMetNo("")
+	mn.BaseURL = srv.URL
+
+	if _, err := mn.Forecast(context.Background(), "45.815", "15.982", time.Now(), time.Now().Add(time.Hour)); err == nil {
+		t.Fatal("expected an error for status 403")
+	}
+	if _, err := mn.Forecast(context.Background(), "nije broj", "15.982", time.Now(), time.Now()); err == nil {
+		t.Fatal("expected an error for an invalid coordinate")
+	}
+}
@@ -1,21 +1,28 @@
 package api
 
+import "time"
+
 // WeatherPodcastByPeriod struktura
 type WeatherPodcastByPeriod struct {
 	Dt   int `json:"dt"`
 	Main struct {
-		Temp     float64 `json:"temp"`
-		Humidity int     `json:"humidity"`
+		Temp      float64 `json:"temp"`
+		FeelsLike float64 `json:"feels_like"`
+		Pressure  float64 `json:"pressure"`
+		Humidity  int     `json:"humidity"`
 	} `json:"main"`
-	Weather []struct {
-		// Main        string `json:"main"`
-		Description string `json:"description"`
-		// Icon        string `json:"icon"`
-	} `json:"weather"`
-	Wind struct {
+	Weather []OneCallWeather `json:"weather"`
+	Wind    struct {
 		Speed float64 `json:"speed"`
+		Deg   int     `json:"deg"`
+		Gust  float64 `json:"gust"`
 	} `json:"wind"`
-	Rain struct {
+	Clouds struct {
+		All int `json:"all"`
+	} `json:"clouds"`
+	Visibility int     `json:"visibility"`
+	Pop        float64 `json:"pop"`
+	Rain       struct {
 		TreeH float64 `json:"3h"`
 	} `json:"rain"`
 	Snow struct {
@@ -31,15 +38,260 @@ type WeatherPodcast struct {
 	List    []WeatherPodcastByPeriod `json:"list"`
 }
 
+// OneCallWeather struktura je opis vremena u Open Weather odgovoru
+type OneCallWeather struct {
+	ID          int    `json:"id"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+}
+
+// OneCallPodcast struktura sadrži satnu i dnevnu prognozu One Call API-ja
+type OneCallPodcast struct {
+	// Timezone je vremenska zona lokacije, a TimezoneOffset
+	// njen pomak od UTC-a u sekundama
+	Timezone       string `json:"timezone"`
+	TimezoneOffset int    `json:"timezone_offset"`
+
+	Hourly []struct {
+		Dt         int64            `json:"dt"`
+		Temp       float64          `json:"temp"`
+		FeelsLike  float64          `json:"feels_like"`
+		Pressure   float64          `json:"pressure"`
+		Humidity   int              `json:"humidity"`
+		Clouds     int              `json:"clouds"`
+		Visibility int              `json:"visibility"`
+		WindSpeed  float64          `json:"wind_speed"`
+		WindDeg    int              `json:"wind_deg"`
+		WindGust   float64          `json:"wind_gust"`
+		Pop        float64          `json:"pop"`
+		Weather    []OneCallWeather `json:"weather"`
+		Rain       struct {
+			OneH float64 `json:"1h"`
+		} `json:"rain"`
+		Snow struct {
+			OneH float64 `json:"1h"`
+		} `json:"snow"`
+	} `json:"hourly"`
+	Daily []struct {
+		Dt   int64 `json:"dt"`
+		Temp struct {
+			Day float64 `json:"day"`
+		} `json:"temp"`
+		FeelsLike struct {
+			Day float64 `json:"day"`
+		} `json:"feels_like"`
+		Pressure  float64          `json:"pressure"`
+		Humidity  int              `json:"humidity"`
+		Clouds    int              `json:"clouds"`
+		WindSpeed float64          `json:"wind_speed"`
+		WindDeg   int              `json:"wind_deg"`
+		WindGust  float64          `json:"wind_gust"`
+		Pop       float64          `json:"pop"`
+		Weather   []OneCallWeather `json:"weather"`
+		// Dnevna prognoza daje ukupne oborine za cijeli dan
+		Rain float64 `json:"rain"`
+		Snow float64 `json:"snow"`
+	} `json:"daily"`
+}
+
+// OpenMeteoPodcast struktura sadrži satne prognoze Open-Meteo API-ja.
+// Svaka lista ima po jednu vrijednost za svako vrijeme iz liste Time.
+type OpenMeteoPodcast struct {
+	Hourly struct {
+		Time          []int64   `json:"time"`
+		Temperature   []float64 `json:"temperature_2m"`
+		FeelsLike     []float64 `json:"apparent_temperature"`
+		Humidity      []int     `json:"relative_humidity_2m"`
+		Pressure      []float64 `json:"surface_pressure"`
+		CloudCover    []float64 `json:"cloud_cover"`
+		Visibility    []float64 `json:"visibility"`
+		WindSpeed     []float64 `json:"wind_speed_10m"`
+		WindDirection []float64 `json:"wind_direction_10m"`
+		WindGusts     []float64 `json:"wind_gusts_10m"`
+		Pop           []float64 `json:"precipitation_probability"`
+		Rain          []float64 `json:"rain"`
+		Snowfall      []float64 `json:"snowfall"`
+		WeatherCode   []int     `json:"weather_code"`
+	} `json:"hourly"`
+}
+
+// MetNoPeriod struktura je sažetak prognoze za idući period od jednog ili šest sati
+type MetNoPeriod struct {
+	Summary struct {
+		SymbolCode string `json:"symbol_code"`
+	} `json:"summary"`
+	Details struct {
+		PrecipitationAmount        float64 `json:"precipitation_amount"`
+		ProbabilityOfPrecipitation float64 `json:"probability_of_precipitation"`
+	} `json:"details"`
+}
+
+// MetNoPodcast struktura sadrži GeoJSON prognozu MET Norway Locationforecast API-ja
+type MetNoPodcast struct {
+	Properties struct {
+		Timeseries []struct {
+			Time string `json:"time"`
+			Data struct {
+				Instant struct {
+					Details struct {
+						AirTemperature    float64 `json:"air_temperature"`
+						AirPressure       float64 `json:"air_pressure_at_sea_level"`
+						CloudAreaFraction float64 `json:"cloud_area_fraction"`
+						RelativeHumidity  float64 `json:"relative_humidity"`
+						WindSpeed         float64 `json:"wind_speed"`
+						WindFromDirection float64 `json:"wind_from_direction"`
+						WindSpeedOfGust   float64 `json:"wind_speed_of_gust"`
+					} `json:"details"`
+				} `json:"instant"`
+				Next1Hours *MetNoPeriod `json:"next_1_hours"`
+				Next6Hours *MetNoPeriod `json:"next_6_hours"`
+			} `json:"data"`
+		} `json:"timeseries"`
+	} `json:"properties"`
+}
+
+// Razmaci između prognoza koje daju izvori
+const (
+	Resolution1h = "1h"
+	Resolution3h = "3h"
+	Resolution6h = "6h"
+	Resolution1d = "1d"
+)
+
 // WeatherData struktura
 type WeatherData struct {
-	Date        string  `json:"date"`
-	Temp        float64 `json:"temp"`
-	Humidity    int     `json:"humidity"`
+	Date     string  `json:"date"`
+	Temp     float64 `json:"temp"`
+	Humidity int     `json:"humidity"`
+	// WeatherIcon je opis vremena na jeziku zahtjeva
 	WeatherIcon string  `json:"weathericon"`
 	WindSpeed   float64 `json:"windspeed"`
 	Rain        float64 `json:"rain"`
 	Snow        float64 `json:"snow"`
+	// Smjer vjetra je u stupnjevima odakle puše, a udari u m/s
+	WindDeg  int     `json:"winddeg"`
+	WindGust float64 `json:"windgust"`
+	// Pressure je tlak u hPa, a Clouds naoblaka u postocima
+	Pressure float64 `json:"pressure"`
+	Clouds   int     `json:"clouds"`
+	// Visibility je vidljivost u metrima, 0 ako je izvor ne daje
+	Visibility int     `json:"visibility"`
+	FeelsLike  float64 `json:"feelslike"`
+	// Pop je vjerojatnost oborina, od 0 do 1
+	Pop float64 `json:"pop"`
+	// ConditionID i IconCode su kod vremena i ikona kako ih daje izvor,
+	// a WMOCode i Condition kod i kategorija zajednički svim izvorima
+	ConditionID int    `json:"conditionid"`
+	IconCode    string `json:"iconcode"`
+	WMOCode     int    `json:"wmocode"`
+	Condition   string `json:"condition"`
+	// Resolution je razmak između prognoza iz kojeg
+	// je ova prognoza, npr. 1h, 3h, 6h ili 1d
+	Resolution string `json:"resolution"`
+	// Ensemble postoji samo za prognoze spojene iz više izvora
+	Ensemble *EnsembleStats `json:"ensemble,omitempty"`
+}
+
+// RaceForecast je niz prognoza za jednu utrku, poredan po vremenu.
+// Provider i FetchedAt su izvor i vrijeme zadnjeg dohvata prognoza.
+type RaceForecast struct {
+	Race      Race          `json:"race"`
+	Provider  string        `json:"provider"`
+	FetchedAt string        `json:"fetched_at,omitempty"`
+	Forecasts []WeatherData `json:"forecasts"`
+}
+
+// PointForecast je niz prognoza za proizvoljnu točku, poredan po vremenu.
+// Lat i Lon su zaokružene koordinate za koje su prognoze dohvaćene,
+// a vremena su u vremenskoj zoni točke.
+type PointForecast struct {
+	Lat       string        `json:"lat"`
+	Lon       string        `json:"lon"`
+	TimeZone  string        `json:"timezone"`
+	Provider  string        `json:"provider"`
+	FetchedAt string        `json:"fetched_at"`
+	Forecasts []WeatherData `json:"forecasts"`
+}
+
+// BatchResult je rezultat jedne točke zahtjeva za prognoze više točaka.
+// Index je mjesto točke u zahtjevu, a postoji ili Forecast ili Error.
+type BatchResult struct {
+	Index    int            `json:"index"`
+	Forecast *PointForecast `json:"forecast,omitempty"`
+	Error    *Problem       `json:"error,omitempty"`
+	// Err je greška točke iz koje handler slaže Error
+	Err error `json:"-"`
+}
+
+// ForecastSummary je sažetak prognoza za interval utrke
+type ForecastSummary struct {
+	Race      Race   `json:"race"`
+	Provider  string `json:"provider"`
+	FetchedAt string `json:"fetched_at,omitempty"`
+	// From i To su vremena prve i zadnje prognoze, a Points broj prognoza
+	From         string  `json:"from,omitempty"`
+	To           string  `json:"to,omitempty"`
+	Points       int     `json:"points"`
+	TempMin      float64 `json:"tempmin"`
+	TempMax      float64 `json:"tempmax"`
+	TempMean     float64 `json:"tempmean"`
+	RainTotal    float64 `json:"raintotal"`
+	SnowTotal    float64 `json:"snowtotal"`
+	WindSpeedMax float64 `json:"windspeedmax"`
+	WindGustMax  float64 `json:"windgustmax"`
+	// WorstCondition je najgora kategorija vremena, a WorstHour
+	// vrijeme prognoze s najgorim uvjetima
+	WorstCondition   string `json:"worstcondition"`
+	WorstDescription string `json:"worstdescription"`
+	WorstHour        string `json:"worsthour"`
+}
+
+// EnsembleStats struktura opisuje koliko se izvori
+// slažu oko prognoze za jedan vremenski trenutak
+type EnsembleStats struct {
+	Sources       []string `json:"sources"`
+	Temp          Spread   `json:"temp"`
+	WindSpeed     Spread   `json:"windspeed"`
+	Precipitation Spread   `json:"precipitation"`
+	// Agreement je prosječna ocjena slaganja, od 0 do 1
+	Agreement float64 `json:"agreement"`
+}
+
+// Spread struktura je srednja vrijednost i raspon
+// jedne veličine preko svih izvora
+type Spread struct {
+	Mean      float64 `json:"mean"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+	Agreement float64 `json:"agreement"`
+}
+
+// BreakerStatus struktura je stanje prekidača jednog izvora prognoze
+type BreakerStatus struct {
+	Provider  string `json:"provider"`
+	State     string `json:"state"`
+	Failures  int    `json:"failures"`
+	LastError string `json:"last_error,omitempty"`
+	OpenedAt  string `json:"opened_at,omitempty"`
+	RetryAt   string `json:"retry_at,omitempty"`
+}
+
+// LimiterStats struktura su podaci o čekanju na ograničenje poziva jednog izvora
+type LimiterStats struct {
+	Provider  string  `json:"provider"`
+	PerMinute float64 `json:"per_minute"`
+	Burst     int     `json:"burst"`
+	// Calls je ukupan broj poziva, a Waited broj poziva koji su morali čekati
+	Calls     int `json:"calls"`
+	Waited    int `json:"waited"`
+	Cancelled int `json:"cancelled"`
+	// Queued je broj poziva koji trenutno čekaju
+	Queued           int           `json:"queued"`
+	TotalWait        time.Duration `json:"-"`
+	MaxWait          time.Duration `json:"-"`
+	TotalWaitSeconds float64       `json:"total_wait_seconds"`
+	MaxWaitSeconds   float64       `json:"max_wait_seconds"`
+	AvgWaitSeconds   float64       `json:"avg_wait_seconds"`
 }
 
 // Race struktura
@@ -50,6 +302,55 @@ type Race struct {
 	Lon   string `json:"lon"`
 	Begin string `json:"begin"`
 	End   string `json:"end"`
+	// TimeZone je vremenska zona utrke u kojoj vraćamo vremena.
+	// Ako nije zadana uz utrku, to je zona lokacije utrke,
+	// a ako ni ona nije poznata vremena su u UTC-u.
+	TimeZone string `json:"timezone"`
+	// RaceZone je zona zadana uz utrku, a prazna je ako utrka koristi
+	// zonu lokacije. Potrebna je kod djelomičnog ažuriranja utrke.
+	RaceZone string `json:"-"`
+}
+
+// NewRace su provjereni podaci nove utrke, s vremenima u UTC-u
+type NewRace struct {
+	Name     string
+	Lat      string
+	Lon      string
+	TimeZone string
+	Start    time.Time
+	End      time.Time
+}
+
+// Statusi utrka u izvještaju o uvozu
+const (
+	ImportValid   = "valid"
+	ImportInvalid = "invalid"
+	ImportCreated = "created"
+	ImportFailed  = "failed"
+	ImportSkipped = "skipped"
+)
+
+// ImportResult je rezultat uvoza jedne utrke. Row je redni broj
+// utrke u uvozu, počevši od 1 i bez zaglavlja CSV datoteke.
+type ImportResult struct {
+	Row    int              `json:"row"`
+	Name   string           `json:"name"`
+	Status string           `json:"status"`
+	ID     int64            `json:"id,omitempty"`
+	Errors ValidationErrors `json:"errors,omitempty"`
+	Error  string           `json:"error,omitempty"`
+}
+
+// ImportReport je izvještaj o uvozu utrka s rezultatom za svaku utrku
+type ImportReport struct {
+	Mode    string         `json:"mode"`
+	DryRun  bool           `json:"dry_run"`
+	Total   int            `json:"total"`
+	Valid   int            `json:"valid"`
+	Invalid int            `json:"invalid"`
+	Created int            `json:"created"`
+	Failed  int            `json:"failed"`
+	Rows    []ImportResult `json:"rows"`
 }
 
 // NotFinishedRace struktura
@@ -69,9 +370,153 @@ type Location struct {
 	Lon string `json:"lon"`
 }
 
+// RetryEntry struktura je lokacija za koju automatsko
+// ažuriranje nije uspjelo dohvatiti prognozu
+type RetryEntry struct {
+	LocID       int    `json:"location_id"`
+	Lat         string `json:"lat"`
+	Lon         string `json:"lon"`
+	Attempts    int    `json:"attempts"`
+	LastError   string `json:"last_error"`
+	NextAttempt string `json:"next_attempt"`
+}
+
 // AllData struktura je za sve
 // prognoze na određenoj lokaciji
 type AllData struct {
-	Loc  int
-	Data []WeatherData
+	Loc      int
+	Provider string
+	Data     []WeatherData
+}
+
+// Envelope je omotnica svih uspješnih odgovora API-ja v2
+type Envelope struct {
+	Data  interface{}            `json:"data"`
+	Meta  map[string]interface{} `json:"meta,omitempty"`
+	Links map[string]string      `json:"links,omitempty"`
+}
+
+// RaceV2 struktura je utrka u API-ju v2
+type RaceV2 struct {
+	ID       int     `json:"id"`
+	Name     string  `json:"name"`
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
+	Start    string  `json:"start"`
+	End      string  `json:"end"`
+	TimeZone string  `json:"time_zone"`
+}
+
+// ForecastV2 struktura je prognoza za jedan trenutak u API-ju v2
+type ForecastV2 struct {
+	Time                     string      `json:"time"`
+	Temperature              float64     `json:"temperature"`
+	FeelsLike                float64     `json:"feels_like"`
+	Humidity                 int         `json:"humidity"`
+	Pressure                 float64     `json:"pressure"`
+	Clouds                   int         `json:"clouds"`
+	Visibility               int         `json:"visibility"`
+	WindSpeed                float64     `json:"wind_speed"`
+	WindDeg                  int         `json:"wind_deg"`
+	WindGust                 float64     `json:"wind_gust"`
+	Rain                     float64     `json:"rain"`
+	Snow                     float64     `json:"snow"`
+	PrecipitationProbability float64     `json:"precipitation_probability"`
+	Description              string      `json:"description"`
+	Condition                string      `json:"condition"`
+	WMOCode                  int         `json:"wmo_code"`
+	ConditionID              int         `json:"condition_id"`
+	IconCode                 string      `json:"icon_code"`
+	Resolution               string      `json:"resolution"`
+	Ensemble                 *EnsembleV2 `json:"ensemble,omitempty"`
+}
+
+// EnsembleV2 struktura je raspon i slaganje izvora u API-ju v2
+type EnsembleV2 struct {
+	Sources       []string `json:"sources"`
+	Temperature   Spread   `json:"temperature"`
+	WindSpeed     Spread   `json:"wind_speed"`
+	Precipitation Spread   `json:"precipitation"`
+	Agreement     float64  `json:"agreement"`
+}
+
+// SummaryV2 struktura je sažetak prognoza za utrku u API-ju v2
+type SummaryV2 struct {
+	From             string  `json:"from,omitempty"`
+	To               string  `json:"to,omitempty"`
+	Points           int     `json:"points"`
+	TemperatureMin   float64 `json:"temperature_min"`
+	TemperatureMax   float64 `json:"temperature_max"`
+	TemperatureMean  float64 `json:"temperature_mean"`
+	RainTotal        float64 `json:"rain_total"`
+	SnowTotal        float64 `json:"snow_total"`
+	WindSpeedMax     float64 `json:"wind_speed_max"`
+	WindGustMax      float64 `json:"wind_gust_max"`
+	WorstCondition   string  `json:"worst_condition"`
+	WorstDescription string  `json:"worst_description"`
+	WorstTime        string  `json:"worst_time"`
+}
+
+// PointForecastV2 su prognoze za jednu točku u API-ju v2
+type PointForecastV2 struct {
+	Lat       float64      `json:"lat"`
+	Lon       float64      `json:"lon"`
+	TimeZone  string       `json:"time_zone"`
+	Provider  string       `json:"provider"`
+	FetchedAt string       `json:"fetched_at"`
+	Forecasts []ForecastV2 `json:"forecasts"`
+}
+
+// BatchResultV2 je rezultat jedne točke zahtjeva za prognoze više točaka u API-ju v2
+type BatchResultV2 struct {
+	Index int              `json:"index"`
+	Data  *PointForecastV2 `json:"data,omitempty"`
+	Error *Problem         `json:"error,omitempty"`
+}
+
+// RaceQuery su filteri, poredak i stranica za popis utrka.
+// Nulte vrijednosti znače da filter nije zadan, a Limit 0 da popis nije ograničen.
+type RaceQuery struct {
+	Limit  int
+	Offset int
+	// StartsAfter i EndsBefore ograničavaju početak i kraj utrke
+	StartsAfter time.Time
+	EndsBefore  time.Time
+	// Status je upcoming za utrke koje nisu završile ili finished za završene
+	Status string
+	// Name je dio naziva utrke, bez obzira na velika i mala slova
+	Name string
+	BBox *BBox
+	// Sort je start, -start, name ili -name, gdje minus znači silazni poredak
+	Sort string
+}
+
+// BBox je pravokutno područje zadano koordinatama rubova.
+// Ako je MinLon veći od MaxLon, područje prelazi 180. meridijan.
+type BBox struct {
+	MinLon, MinLat, MaxLon, MaxLat float64
+}
+
+// NearbyQuery su parametri pretrage utrka po udaljenosti od točke
+type NearbyQuery struct {
+	// Point je točka od koje računamo udaljenost utrka
+	Point Point
+	// RadiusKm ograničava udaljenost utrka, a 0 znači da nije ograničena
+	RadiusKm float64
+	// Area ograničava utrke na područje, a nil znači da nije ograničeno
+	Area   *Area
+	Status string
+	Limit  int
+}
+
+// NearbyRace je utrka s udaljenošću od tražene točke
+type NearbyRace struct {
+	Race
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// NearbyRaceV2 je utrka s udaljenošću od tražene točke u API-ju v2
+type NearbyRaceV2 struct {
+	RaceV2
+	DistanceKm float64 `json:"distance_km"`
 }
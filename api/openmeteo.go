@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// OpenMeteoURL je adresa Open-Meteo API-ja za prognozu.
+// Open-Meteo ne zahtijeva API ključ pa je pogodan za razvoj.
+const OpenMeteoURL = "https://api.open-meteo.com/v1/forecast"
+
+// OpenMeteo je izvor prognoze koji koristi Open-Meteo API
+type OpenMeteo struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewOpenMeteo kreira novi Open-Meteo izvor prognoze
+func NewOpenMeteo() *OpenMeteo {
+	return &OpenMeteo{
+		BaseURL: OpenMeteoURL,
+		Client: &http.Client{
+			Timeout: time.Second * 2,
+		},
+	}
+}
+
+// Name vraća naziv izvora prognoze
+func (om *OpenMeteo) Name() string {
+	return "openmeteo"
+}
+
+// Forecast dohvaća satne prognoze za određenu lokaciju,
+// pretvara ih u WeatherData i vraća samo one u intervalu utrke.
+func (om *OpenMeteo) Forecast(ctx context.Context, lat, lon string, start, end time.Time) (data []WeatherData, err error) {
+
+	// Brzinu vjetra tražimo u m/s, a vrijeme u unix sekundama,
+	// kako bi podaci bili isti kao kod Open Weather API-ja.
+	url := fmt.Sprintf("%s?latitude=%s&longitude=%s"+
+		"&hourly=temperature_2m,apparent_temperature,relative_humidity_2m,surface_pressure,"+
+		"cloud_cover,visibility,wind_speed_10m,wind_direction_10m,wind_gusts_10m,"+
+		"precipitation_probability,rain,snowfall,weather_code"+
+		"&wind_speed_unit=ms&timeformat=unixtime&timezone=UTC",
+		om.BaseURL, lat, lon)
+
+	filteredForecastData := []WeatherData{}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return filteredForecastData, err
+	}
+	req = req.WithContext(ctx)
+
+	res, err := om.Client.Do(req)
+	if err != nil {
+		return filteredForecastData, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return filteredForecastData, fmt.Errorf("openmeteo: neočekivan status %s", res.Status)
+	}
+
+	var forecastData OpenMeteoPodcast
+	if err := json.NewDecoder(res.Body).Decode(&forecastData); err != nil {
+		return filteredForecastData, err
+	}
+
+	h := forecastData.Hourly
+	for i := range h.Time {
+		t := time.Unix(h.Time[i], 0).UTC()
+		if !inRaceInterval(t, start, end) {
+			continue
+		}
+
+		temp := WeatherData{Date: t.Format(time.RFC3339), Resolution: Resolution1h}
+		if i < len(h.Temperature) {
+			temp.Temp = h.Temperature[i]
+		}
+		if i < len(h.Humidity) {
+			temp.Humidity = h.Humidity[i]
+		}
+		if i < len(h.FeelsLike) {
+			temp.FeelsLike = h.FeelsLike[i]
+		}
+		if i < len(h.Pressure) {
+			temp.Pressure = h.Pressure[i]
+		}
+		if i < len(h.CloudCover) {
+			temp.Clouds = int(h.CloudCover[i])
+		}
+		if i < len(h.Visibility) {
+			temp.Visibility = int(h.Visibility[i])
+		}
+		if i < len(h.WindSpeed) {
+			temp.WindSpeed = h.WindSpeed[i]
+		}
+		if i < len(h.WindDirection) {
+			temp.WindDeg = int(h.WindDirection[i])
+		}
+		if i < len(h.WindGusts) {
+			temp.WindGust = h.WindGusts[i]
+		}
+		// Open-Meteo vraća vjerojatnost oborina u postocima
+		if i < len(h.Pop) {
+			temp.Pop = h.Pop[i] / 100
+		}
+		if i < len(h.Rain) {
+			temp.Rain = h.Rain[i]
+		}
+		// Open-Meteo vraća snijeg u centimetrima,
+		// a u bazi ga držimo u milimetrima kao Open Weather.
+		if i < len(h.Snowfall) {
+			temp.Snow = h.Snowfall[i] * 10
+		}
+		if i < len(h.WeatherCode) {
+			temp.ConditionID = h.WeatherCode[i]
+			temp.setCondition(h.WeatherCode[i])
+		}
+		filteredForecastData = append(filteredForecastData, temp)
+	}
+
+	return filteredForecastData, nil
+}
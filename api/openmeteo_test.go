@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestOpenMeteoForecast(t *testing.T) {
+	base := time.Date(2030, 5, 4, 9, 0, 0, 0, time.UTC)
+	body := fmt.Sprintf(`{"hourly": {
+		"time": [%d, %d, %d],
+		"temperature_2m": [10, 11.5, 12],
+		"apparent_temperature": [9, 10.5, 11],
+		"relative_humidity_2m": [80, 75, 70],
+		"surface_pressure": [1010, 1011, 1012],
+		"cloud_cover": [20, 60, 100],
+		"visibility": [24000, 12000, 800],
+		"wind_speed_10m": [1, 2.5, 4],
+		"wind_direction_10m": [90, 180, 270],
+		"wind_gusts_10m": [3, 5, 8],
+		"precipitation_probability": [0, 40, 90],
+		"rain": [0, 0.8, 0],
+		"snowfall": [0, 0, 0.7],
+		"weather_code": [0, 61]
+	}}`, base.Unix(), base.Add(time.Hour).Unix(), base.Add(2*time.Hour).Unix())
+
+	srv := newTestServer(http.StatusOK, body, func(r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("latitude") != "45.81" || q.Get("longitude") != "15.98" || q.Get("timeformat") != "unixtime" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+	})
+	defer srv.Close()
+	om := NewOpenMeteo()
+	om.BaseURL = srv.URL
+
+	data, err := om.Forecast(context.Background(), "45.81", "15.98", base.Add(time.Hour), base.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 {
+		t.Fatalf("got %d forecasts, want 2", len(data))
+	}
+
+	d := data[0]
+	if d.Date != "2030-05-04T10:00:00Z" || d.Resolution != Resolution1h {
+		t.Errorf("unexpected date or resolution %+v", d)
+	}
+	if d.Temp != 11.5 || d.FeelsLike != 10.5 || d.Humidity != 75 || d.Pressure != 1011 {
+		t.Errorf("unexpected temperature data %+v", d)
+	}
+	if d.Clouds != 60 || d.Visibility != 12000 || d.WindSpeed != 2.5 || d.WindDeg != 180 || d.WindGust != 5 {
+		t.Errorf("unexpected wind or cloud data %+v", d)
+	}
+	if d.Pop != 0.4 || d.Rain != 0.8 || d.Snow != 0 {
+		t.Errorf("unexpected precipitation data %+v", d)
+	}
+	if d.WeatherIcon != "slaba kiša" || d.ConditionID != 61 || d.WMOCode != 61 || d.Condition != ConditionRain {
+		t.Errorf("unexpected condition %+v", d)
+	}
+
+	// Snijeg je zadan u centimetrima, a vremena za zadnji sat nema
+	d = data[1]
+	if d.Snow != 7 || d.Pop != 0.9 || d.WeatherIcon != "" || d.Condition != "" {
+		t.Errorf("unexpected second forecast %+v", d)
+	}
+}
+
+func TestOpenMeteoStatusError(t *testing.T) {
+	srv := newTestServer(http.StatusBadRequest, `{"error": true, "reason": "Latitude must be in range"}`, nil)
+	defer srv.Close()
+	om := NewOpenMeteo()
+	om.BaseURL = srv.URL
+
+	if _, err := om.Forecast(context.Background(), "95", "15.98", time.Now(), time.Now().Add(time.Hour)); err == nil {
+		t.Fatal("expected an error for status 400")
+	}
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// OpenWeatherURL je adresa Open Weather API-ja za prognozu u koracima od 3 sata
+const OpenWeatherURL = "https://api.openweathermap.org/data/2.5/forecast"
+
+// OpenWeatherOneCallURL je adresa Open Weather One Call API-ja
+// koji daje satnu prognozu za 48 sati i dnevnu prognozu za 8 dana
+const OpenWeatherOneCallURL = "https://api.openweathermap.org/data/3.0/onecall"
+
+// OpenWeather je izvor prognoze koji koristi Open Weather API
+type OpenWeather struct {
+	APIKey     string
+	BaseURL    string
+	OneCallURL string
+	// OneCall uključuje One Call API umjesto prognoze u koracima od 3 sata
+	OneCall bool
+	Client  *http.Client
+}
+
+// NewOpenWeather kreira novi Open Weather izvor prognoze
+func NewOpenWeather(apiKey string) *OpenWeather {
+	return &OpenWeather{
+		APIKey:     apiKey,
+		BaseURL:    OpenWeatherURL,
+		OneCallURL: OpenWeatherOneCallURL,
+		// Timeout postavljamo na maksimum 2 sekunde,
+		// kako bi izbjegli zastoj servisa u slučaju nedostupnosti poslužitelja
+		Client: &http.Client{
+			Timeout: time.Second * 2,
+		},
+	}
+}
+
+// Name vraća naziv izvora prognoze
+func (ow *OpenWeather) Name() string {
+	return "openweather"
+}
+
+// Forecast dohvaća prognoze za određenu lokaciju,
+// filtrira ih preuzimajući samo one prognoze koje nam trebaju i to vraća.
+func (ow *OpenWeather) Forecast(ctx context.Context, lat, lon string, start, end time.Time) (data []WeatherData, err error) {
+	if ow.OneCall {
+		return ow.oneCallForecast(ctx, lat, lon, start, end)
+	}
+
+	url := fmt.Sprintf("%s?lat=%s&lon=%s&units=metric&lang=hr&APPID=%s", ow.BaseURL, lat, lon, ow.APIKey)
+
+	// Incijaliziramo praznu listu strukture WeatherPodcastByPeriod.
+	// U tu listu ćemo spremiti samo one prognoze koje nam odgovaraju
+	filteredForecastData := []WeatherData{}
+
+	// Pri čitanju spremamo samo one podatke prognoze koje nam trebaju,
+	// a definirani su u strukturi WeatherPodcast
+	var forecastData WeatherPodcast
+	if err := ow.get(ctx, url, &forecastData); err != nil {
+		return filteredForecastData, err
+	}
+
+	// Preko Weather API-ja primili smo podatke za idućih pet dana,
+	// ali nama trebaju samo podaci u intervalu utrke.
+	temp := WeatherData{Resolution: Resolution3h}
+	// Sami odgovor OpenWeather API-ja nam govori kollika je lista prognoza u atributu Cnt
+	for i := 0; i < forecastData.Cnt && i < len(forecastData.List); i++ {
+
+		// Pretvaramo vrijeme prognoze u tip podataka Time prikladnim za rukovanje
+		t := time.Unix(int64(forecastData.List[i].Dt), 0).UTC()
+
+		// Preuzimamo samo one prognoze koje su
+		// u intervalu utrke
+		if inRaceInterval(t, start, end) {
+			temp.Date = t.Format(time.RFC3339)
+			temp.Humidity = forecastData.List[i].Main.Humidity
+			temp.Temp = forecastData.List[i].Main.Temp
+			temp.FeelsLike = forecastData.List[i].Main.FeelsLike
+			temp.Pressure = forecastData.List[i].Main.Pressure
+			temp.Rain = forecastData.List[i].Rain.TreeH
+			temp.WindSpeed = forecastData.List[i].Wind.Speed
+			temp.WindDeg = forecastData.List[i].Wind.Deg
+			temp.WindGust = forecastData.List[i].Wind.Gust
+			temp.Clouds = forecastData.List[i].Clouds.All
+			temp.Visibility = forecastData.List[i].Visibility
+			temp.Pop = forecastData.List[i].Pop
+			temp.WeatherIcon, temp.ConditionID, temp.IconCode = "", 0, ""
+			temp.WMOCode, temp.Condition = 0, ""
+			if len(forecastData.List[i].Weather) > 0 {
+				temp.setOpenWeatherCondition(forecastData.List[i].Weather[0])
+			}
+			temp.Snow = forecastData.List[i].Snow.TreeH
+			filteredForecastData = append(filteredForecastData, temp)
+		}
+	}
+
+	return filteredForecastData, nil
+}
+
+// oneCallForecast dohvaća prognoze preko One Call API-ja. Za prvih 48 sati
+// uzimamo satnu prognozu, a za dane nakon toga dnevnu prognozu.
+func (ow *OpenWeather) oneCallForecast(ctx context.Context, lat, lon string, start, end time.Time) (data []WeatherData, err error) {
+
+	url := fmt.Sprintf("%s?lat=%s&lon=%s&exclude=current,minutely,alerts&units=metric&lang=hr&appid=%s",
+		ow.OneCallURL, lat, lon, ow.APIKey)
+
+	filteredForecastData := []WeatherData{}
+
+	var forecastData OneCallPodcast
+	if err := ow.get(ctx, url, &forecastData); err != nil {
+		return filteredForecastData, err
+	}
+
+	// Satne prognoze pokrivaju vrijeme do zadnjeg sata,
+	// a dnevne uzimamo samo za dane iza toga.
+	var hourlyEnd time.Time
+	for _, h := range forecastData.Hourly {
+		t := time.Unix(h.Dt, 0).UTC()
+		hourlyEnd = t
+		if !inRaceInterval(t, start, end) {
+			continue
+		}
+		temp := WeatherData{
+			Date:       t.Format(time.RFC3339),
+			Temp:       h.Temp,
+			FeelsLike:  h.FeelsLike,
+			Pressure:   h.Pressure,
+			Humidity:   h.Humidity,
+			Clouds:     h.Clouds,
+			Visibility: h.Visibility,
+			WindSpeed:  h.WindSpeed,
+			WindDeg:    h.WindDeg,
+			WindGust:   h.WindGust,
+			Pop:        h.Pop,
+			Rain:       h.Rain.OneH,
+			Snow:       h.Snow.OneH,
+			Resolution: Resolution1h,
+		}
+		if len(h.Weather) > 0 {
+			temp.setOpenWeatherCondition(h.Weather[0])
+		}
+		filteredForecastData = append(filteredForecastData, temp)
+	}
+
+	// Granice dana računamo u vremenskoj zoni lokacije,
+	// a ne u zoni poslužitelja
+	loc := forecastData.location()
+	for _, d := range forecastData.Daily {
+		// Dnevna prognoza ima vrijeme u podne, a vrijedi za cijeli dan,
+		// pa je uzimamo ako se dan preklapa s intervalom utrke.
+		// Dane čije je podne pokriveno satnom prognozom preskačemo.
+		t := time.Unix(d.Dt, 0).In(loc)
+		year, month, day := t.Date()
+		dayStart := time.Date(year, month, day, 0, 0, 0, 0, loc)
+		dayEnd := dayStart.AddDate(0, 0, 1)
+		if !t.After(hourlyEnd) || !dayStart.Before(end) || !dayEnd.After(start) {
+			continue
+		}
+		temp := WeatherData{
+			Date:       t.UTC().Format(time.RFC3339),
+			Temp:       d.Temp.Day,
+			FeelsLike:  d.FeelsLike.Day,
+			Pressure:   d.Pressure,
+			Humidity:   d.Humidity,
+			Clouds:     d.Clouds,
+			WindSpeed:  d.WindSpeed,
+			WindDeg:    d.WindDeg,
+			WindGust:   d.WindGust,
+			Pop:        d.Pop,
+			Rain:       d.Rain,
+			Snow:       d.Snow,
+			Resolution: Resolution1d,
+		}
+		if len(d.Weather) > 0 {
+			temp.setOpenWeatherCondition(d.Weather[0])
+		}
+		filteredForecastData = append(filteredForecastData, temp)
+	}
+
+	return filteredForecastData, nil
+}
+
+// location vraća vremensku zonu lokacije prognoze. Ako naziv zone
+// nije poznat, koristi pomak od UTC-a iz odgovora.
+func (p *OneCallPodcast) location() *time.Location {
+	if loc, err := time.LoadLocation(p.Timezone); err == nil && p.Timezone != "" {
+		return loc
+	}
+	return time.FixedZone("", p.TimezoneOffset)
+}
+
+// setOpenWeatherCondition postavlja kod, ikonu i kategoriju vremena
+// iz Open Weather opisa. Opis izvora ostaje ako kod nije poznat.
+func (d *WeatherData) setOpenWeatherCondition(w OneCallWeather) {
+	d.WeatherIcon = w.Description
+	d.ConditionID = w.ID
+	d.IconCode = w.Icon
+	d.setCondition(owmToWMO(w.ID))
+}
+
+// get šalje zahtjev Open Weather API-ju i čita JSON odgovor u v
+func (ow *OpenWeather) get(ctx context.Context, url string, v interface{}) error {
+
+	// Izrada zahtjeva
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	// Slanje zahtijeva poslužitelju preko klijenta
+	// "Do" šalje HTTP zahtjeva i vraća jedan
+	res, err := ow.Client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	// Potrebno je zatvoriti res.Body
+	// nakon čitanja podataka iz njega.
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("openweather: neočekivan status %s", res.Status)
+	}
+
+	// Koristimo json.Decode za čitanje strema JSON podataka.
+	return json.NewDecoder(res.Body).Decode(v)
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestServer pokreće HTTP poslužitelj koji na svaki zahtjev vraća
+// zadani status i tijelo, a zahtjev prije toga predaje funkciji check.
+// Pozivatelj mora zatvoriti poslužitelj.
+func newTestServer(status int, body string, check func(r *http.Request)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil {
+			check(r)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+}
+
+// mustParseTime čita vrijeme prognoze u RFC 3339 formatu
+func mustParseTime(t *testing.T, value string) time.Time {
+	tm, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tm
+}
+
+func TestOpenWeatherForecast(t *testing.T) {
+	base := time.Date(2030, 5, 4, 9, 0, 0, 0, time.UTC)
+	body := fmt.Sprintf(`{"cod": "200", "cnt": 3, "list": [
+		{"dt": %d, "main": {"temp": 10, "humidity": 80}, "weather": [{"id": 800, "description": "clear sky", "icon": "01d"}]},
+		{"dt": %d, "main": {"temp": 12.5, "feels_like": 11, "pressure": 1012, "humidity": 70},
+		 "weather": [{"id": 500, "description": "light rain", "icon": "10d"}],
+		 "wind": {"speed": 3.5, "deg": 200, "gust": 6}, "clouds": {"all": 75},
+		 "visibility": 10000, "pop": 0.6, "rain": {"3h": 1.5}},
+		{"dt": %d, "main": {"temp": 14, "humidity": 60}, "weather": [{"id": 601, "description": "snow", "icon": "13d"}],
+		 "snow": {"3h": 2}}
+	]}`, base.Unix(), base.Add(3*time.Hour).Unix(), base.Add(6*time.Hour).Unix())
+
+	srv := newTestServer(http.StatusOK, body, func(r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("lat") != "45.81" || q.Get("lon") != "15.98" || q.Get("APPID") != "kljuc" || q.Get("units") != "metric" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+	})
+	defer srv.Close()
+	ow := NewOpenWeather("kljuc")
+	ow.BaseURL = srv.URL
+
+	data, err := ow.Forecast(context.Background(), "45.81", "15.98", base.Add(time.Hour), base.Add(6*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 {
+		t.Fatalf("got %d forecasts, want 2", len(data))
+	}
+
+	d := data[0]
+	// Vremena vraćamo u UTC-u, bez obzira na zonu poslužitelja
+	if d.Date != "2030-05-04T12:00:00Z" {
+		t.Errorf("date = %s, want 2030-05-04T12:00:00Z", d.Date)
+	}
+	if d.Temp != 12.5 || d.FeelsLike != 11 || d.Pressure != 1012 || d.Humidity != 70 {
+		t.Errorf("unexpected temperature data %+v", d)
+	}
+	if d.WindSpeed != 3.5 || d.WindDeg != 200 || d.WindGust != 6 || d.Clouds != 75 || d.Visibility != 10000 {
+		t.Errorf("unexpected wind or cloud data %+v", d)
+	}
+	if d.Rain != 1.5 || d.Snow != 0 || d.Pop != 0.6 || d.Resolution != Resolution3h {
+		t.Errorf("unexpected precipitation data %+v", d)
+	}
+	// Opis izvora zamjenjujemo hrvatskim opisom WMO koda
+	if d.WeatherIcon != "slaba kiša" || d.ConditionID != 500 || d.IconCode != "10d" || d.WMOCode != 61 || d.Condition != ConditionRain {
+		t.Errorf("unexpected condition %+v", d)
+	}
+
+	// Podaci prethodne prognoze ne smiju ostati u idućoj
+	d = data[1]
+	if d.Rain != 0 || d.Snow != 2 || d.WindSpeed != 0 || d.WeatherIcon != "snijeg" || d.Condition != ConditionSnow {
+		t.Errorf("unexpected second forecast %+v", d)
+	}
+}
+
+func TestOpenWeatherOneCallForecast(t *testing.T) {
+	base := time.Date(2030, 5, 4, 9, 0, 0, 0, time.UTC)
+	noon := func(day int) int64 { return time.Date(2030, 5, day, 12, 0, 0, 0, time.UTC).Unix() }
+	body := fmt.Sprintf(`{
+		"hourly": [
+			{"dt": %d, "temp": 15, "humidity": 55, "wind_speed": 2, "wind_deg": 90, "pop": 0.1,
+			 "weather": [{"id": 800, "description": "clear sky", "icon": "01d"}]},
+			{"dt": %d, "temp": 16, "humidity": 50, "rain": {"1h": 0.4},
+			 "weather": [{"id": 500, "description": "light rain", "icon": "10d"}]}
+		],
+		"daily": [
+			{"dt": %d, "temp": {"day": 18}, "weather": [{"id": 800, "description": "clear sky", "icon": "01d"}]},
+			{"dt": %d, "temp": {"day": 3}, "feels_like": {"day": 0}, "humidity": 90, "pop": 0.8, "snow": 12,
+			 "weather": [{"id": 601, "description": "snow", "icon": "13d"}]},
+			{"dt": %d, "temp": {"day": 20}, "weather": [{"id": 800, "description": "clear sky", "icon": "01d"}]}
+		]}`, base.Unix(), base.Add(time.Hour).Unix(), noon(3), noon(5), noon(9))
+
+	srv := newTestServer(http.StatusOK, body, func(r *http.Request) {
+		if r.URL.Query().Get("appid") != "kljuc" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+	})
+	defer srv.Close()
+	ow := NewOpenWeather("kljuc")
+	ow.OneCall = true
+	ow.OneCallURL = srv.URL
+
+	data, err := ow.Forecast(context.Background(), "45.81", "15.98", base, time.Date(2030, 5, 6, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 3 {
+		t.Fatalf("got %d forecasts, want 2 hourly and 1 daily", len(data))
+	}
+
+	if data[0].Resolution != Resolution1h || data[0].Temp != 15 || data[0].WindDeg != 90 || data[0].Pop != 0.1 ||
+		data[0].Condition != ConditionClear {
+		t.Errorf("unexpected first hourly forecast %+v", data[0])
+	}
+	if data[1].Rain != 0.4 || data[1].WeatherIcon != "slaba kiša" || data[1].ConditionID != 500 || data[1].Condition != ConditionRain {
+		t.Errorf("unexpected second hourly forecast %+v", data[1])
+	}
+
+	// Dnevnu prognozu za dan pokriven satnom prognozom preskačemo
+	d := data[2]
+	if d.Date != "2030-05-05T12:00:00Z" {
+		t.Errorf("daily date = %s, want 5 May at noon", d.Date)
+	}
+	if d.Resolution != Resolution1d || d.Temp != 3 || d.FeelsLike != 0 || d.Snow != 12 || d.Humidity != 90 || d.Pop != 0.8 {
+		t.Errorf("unexpected daily forecast %+v", d)
+	}
+	if d.ConditionID != 601 || d.IconCode != "13d" || d.WMOCode != 73 || d.WeatherIcon != "snijeg" || d.Condition != ConditionSnow {
+		t.Errorf("unexpected daily condition %+v", d)
+	}
+}
+
+func TestOpenWeatherOneCallDayInVenueZone(t *testing.T) {
+	// Podne u New Yorku je u 16 sati po UTC-u
+	noon := func(day int) int64 { return time.Date(2030, 5, day, 16, 0, 0, 0, time.UTC).Unix() }
+	body := fmt.Sprintf(`{
+		"timezone": "America/New_York",
+		"timezone_offset": -14400,
+		"hourly": [],
+		"daily": [
+			{"dt": %d, "temp": {"day": 18}, "weather": [{"id": 800}]},
+			{"dt": %d, "temp": {"day": 20}, "weather": [{"id": 800}]}
+		]}`, noon(4), noon(5))
+
+	srv := newTestServer(http.StatusOK, body, nil)
+	defer srv.Close()
+	ow := NewOpenWeather("kljuc")
+	ow.OneCall = true
+	ow.OneCallURL = srv.URL
+
+	// Utrka je navečer 4. svibnja u New Yorku, a to je 5. svibnja po UTC-u
+	start := time.Date(2030, 5, 5, 0, 0, 0, 0, time.UTC)
+	data, err := ow.Forecast(context.Background(), "40.71", "-74.01", start, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || data[0].Temp != 18 || data[0].Date != "2030-05-04T16:00:00Z" {
+		t.Errorf("got %+v, want only the forecast for 4 May", data)
+	}
+}
+
+func TestOpenWeatherStatusError(t *testing.T) {
+	srv := newTestServer(http.StatusUnauthorized, `{"cod": 401}`, nil)
+	defer srv.Close()
+	ow := NewOpenWeather("pogresan")
+	ow.BaseURL = srv.URL
+
+	data, err := ow.Forecast(context.Background(), "45.81", "15.98", time.Now(), time.Now().Add(time.Hour))
+	if err == nil {
+		t.Fatal("expected an error for status 401")
+	}
+	if data == nil || len(data) != 0 {
+		t.Errorf("got %v, want an empty list", data)
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WeatherProvider je sučelje koje mora zadovoljiti svaki izvor vremenske prognoze.
+// Handleri i proces automatskog ažuriranja ne znaju s kojim izvorom rade,
+// pa je izvore moguće mijenjati, a u testovima koristiti i lažni izvor bez mreže.
+type WeatherProvider interface {
+	// Name vraća naziv izvora prognoze
+	Name() string
+	// Forecast dohvaća prognoze za zadanu lokaciju i vraća
+	// samo one koje su unutar intervala od start do end.
+	Forecast(ctx context.Context, lat, lon string, start, end time.Time) ([]WeatherData, error)
+}
+
+// ProviderOptions su postavke potrebne pojedinim izvorima prognoze
+type ProviderOptions struct {
+	OpenWeatherAPIKey  string
+	OpenWeatherOneCall bool
+	MetNoUserAgent     string
+	// RateLimits su ograničenja poziva po nazivu izvora,
+	// a za izvore kojih nema koriste se DefaultRateLimits.
+	RateLimits map[string]RateLimit
+}
+
+// NewProvider kreira izvor prognoze prema njegovom nazivu.
+// Open Weather izvor zahtijeva API ključ, ostali ga ignoriraju.
+// Više naziva odvojenih zarezom daje izvor koji spaja njihove prognoze,
+// a nazivi odvojeni s | daju lanac izvora sa prekidačima.
+func NewProvider(name string, opts ProviderOptions) (WeatherProvider, error) {
+	if strings.Contains(name, ",") {
+		var providers []WeatherProvider
+		for _, n := range strings.Split(name, ",") {
+			p, err := NewProvider(strings.TrimSpace(n), opts)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, p)
+		}
+		return NewEnsemble(providers...), nil
+	}
+
+	// Nazivi odvojeni s | daju lanac izvora koji se pokušavaju redom
+	if strings.Contains(name, "|") {
+		var providers []WeatherProvider
+		for _, n := range strings.Split(name, "|") {
+			p, err := NewProvider(strings.TrimSpace(n), opts)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, p)
+		}
+		return NewFailover(providers...), nil
+	}
+
+	// Svaki poziv prema izvoru prolazi kroz ograničenje
+	// koje je zajedničko za cijeli proces.
+	var provider WeatherProvider
+	switch name {
+	case "openweather":
+		ow := NewOpenWeather(opts.OpenWeatherAPIKey)
+		ow.OneCall = opts.OpenWeatherOneCall
+		provider = ow
+	case "openmeteo", "":
+		provider = NewOpenMeteo()
+	case "metno":
+		provider = NewMetNo(opts.MetNoUserAgent)
+	default:
+		return nil, fmt.Errorf("nepoznat izvor prognoze: %s", name)
+	}
+	return &rateLimited{provider, limiterFor(provider.Name(), opts.RateLimits)}, nil
+}
+
+// inRaceInterval provjerava da li je vrijeme prognoze unutar intervala utrke
+func inRaceInterval(t, start, end time.Time) bool {
+	return (t.After(start) || t.Equal(start)) && (t.Before(end) || t.Equal(end))
+}
+
+// updateInterval vraća interval za koji automatsko ažuriranje dohvaća prognoze,
+// od sadašnjeg trenutka pa do 15. veljače iduće godine.
+func updateInterval() (start, end time.Time) {
+	start = time.Now().UTC()
+	year, _, _ := start.Date()
+	end = time.Date(year+1, 2, 15, 0, 0, 0, 0, time.UTC)
+	return start, end
+}
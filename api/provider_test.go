@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProvider je lažni izvor prognoze bez mreže. Za svaku lokaciju vraća
+// satne prognoze od start do end s temperaturom jednakom satu prognoze,
+// pa je rezultat uvijek isti. Ako je zadan err, vraća samo grešku, a ako je
+// zadan release, čeka da se kanal zatvori ili da se prekine ctx.
+type fakeProvider struct {
+	name    string
+	err     error
+	release chan struct{}
+
+	mu    sync.Mutex
+	calls []Location
+}
+
+func (p *fakeProvider) Name() string {
+	if p.name == "" {
+		return "fake"
+	}
+	return p.name
+}
+
+func (p *fakeProvider) Forecast(ctx context.Context, lat, lon string, start, end time.Time) ([]WeatherData, error) {
+	p.mu.Lock()
+	p.calls = append(p.calls, Location{lat, lon})
+	err := p.err
+	p.mu.Unlock()
+
+	if p.release != nil {
+		select {
+		case <-p.release:
+		case <-ctx.Done():
+			return []WeatherData{}, ctx.Err()
+		}
+	}
+	if err != nil {
+		return []WeatherData{}, err
+	}
+
+	data := []WeatherData{}
+	for t := start.UTC().Truncate(time.Hour); !t.After(end); t = t.Add(time.Hour) {
+		if t.Before(start) {
+			continue
+		}
+		d := WeatherData{
+			Date:       t.Format(time.RFC3339),
+			Temp:       float64(t.Hour()),
+			Humidity:   50,
+			WindSpeed:  2,
+			Resolution: Resolution1h,
+		}
+		d.setCondition(0)
+		data = append(data, d)
+	}
+	return data, nil
+}
+
+// Calls vraća broj poziva izvora
+func (p *fakeProvider) Calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.calls)
+}
+
+func TestFakeProvider(t *testing.T) {
+	start := time.Date(2030, 5, 4, 9, 30, 0, 0, time.UTC)
+	data, err := (&fakeProvider{}).Forecast(context.Background(), "45.81", "15.98", start, start.Add(3*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2030-05-04T10:00:00Z", "2030-05-04T11:00:00Z", "2030-05-04T12:00:00Z"}
+	if len(data) != len(want) {
+		t.Fatalf("got %d forecasts, want %d", len(data), len(want))
+	}
+	for i, d := range data {
+		if d.Date != want[i] {
+			t.Errorf("forecast %d: got %s, want %s", i, d.Date, want[i])
+		}
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"", "openmeteo"},
+		{"openmeteo", "openmeteo"},
+		{"openweather", "openweather"},
+		{"metno", "metno"},
+		{"openweather, metno", "ensemble(openweather,metno)"},
+		{"openmeteo|metno", "failover(openmeteo,metno)"},
+		{"openmeteo|metno,openweather", "ensemble(failover(openmeteo,metno),openweather)"},
+	}
+	for _, tt := range tests {
+		p, err := NewProvider(tt.name, ProviderOptions{})
+		if err != nil {
+			t.Errorf("NewProvider(%q): %v", tt.name, err)
+			continue
+		}
+		if p.Name() != tt.want {
+			t.Errorf("NewProvider(%q).Name() = %q, want %q", tt.name, p.Name(), tt.want)
+		}
+	}
+
+	if _, err := NewProvider("openmeteo,nepostojeci", ProviderOptions{}); err == nil || !strings.Contains(err.Error(), "nepostojeci") {
+		t.Errorf("unknown provider: got %v", err)
+	}
+}
+
+func TestUpdateIntervalIsUTC(t *testing.T) {
+	start, end := updateInterval()
+	if start.Location() != time.UTC || end.Location() != time.UTC {
+		t.Errorf("got %v and %v, want UTC", start.Location(), end.Location())
+	}
+	if end.Month() != time.February || end.Day() != 15 || end.Year() != start.Year()+1 {
+		t.Errorf("end = %v, want 15 February next year", end)
+	}
+}
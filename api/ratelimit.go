@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RateLimit je dozvoljeni broj poziva izvora prognoze u minuti
+// i najveći broj poziva koji se mogu napraviti odjednom.
+type RateLimit struct {
+	PerMinute float64
+	Burst     int
+}
+
+// DefaultRateLimits su ograničenja pojedinih izvora prognoze.
+// Besplatna verzija Open Weather API-ja dozvoljava 60 zahtjeva po minuti,
+// ali ne riskiramo s brojem od 60, nego uzimamo sigurniji broj od 45.
+var DefaultRateLimits = map[string]RateLimit{
+	"openweather": {PerMinute: 45, Burst: 5},
+	"openmeteo":   {PerMinute: 500, Burst: 20},
+	"metno":       {PerMinute: 600, Burst: 20},
+}
+
+// TokenBucket ograničava broj poziva prema jednom izvoru prognoze.
+// Svaki poziv troši jedan token, a tokeni se obnavljaju brzinom
+// od PerMinute u minuti do najviše Burst tokena. Pozivi koji nemaju
+// token čekaju u redu redoslijedom kojim su došli.
+type TokenBucket struct {
+	mu     sync.Mutex
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+	stats  LimiterStats
+}
+
+// NewTokenBucket kreira novo puno ograničenje
+func NewTokenBucket(limit RateLimit) *TokenBucket {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	if limit.PerMinute <= 0 {
+		limit.PerMinute = 60
+	}
+	return &TokenBucket{
+		limit:  limit,
+		tokens: float64(limit.Burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait čeka dok ne bude slobodan token za poziv. Ako se ctx prekine
+// prije toga, token se vraća i Wait vraća grešku iz ctx.
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	b.mu.Lock()
+	now := time.Now()
+	rate := b.limit.PerMinute / 60
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > float64(b.limit.Burst) {
+		b.tokens = float64(b.limit.Burst)
+	}
+	b.last = now
+
+	// Uzimamo token odmah, a ako ga nema čekamo koliko
+	// je potrebno da se obnove svi tokeni koji su uzeti prije nas.
+	b.tokens--
+	var wait time.Duration
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / rate * float64(time.Second))
+	}
+	b.stats.Calls++
+	b.stats.Queued++
+	b.mu.Unlock()
+
+	var err error
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			err = ctx.Err()
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stats.Queued--
+	if err != nil {
+		b.tokens++
+		b.stats.Cancelled++
+		return err
+	}
+	if wait > 0 {
+		b.stats.Waited++
+		b.stats.TotalWait += wait
+		if wait > b.stats.MaxWait {
+			b.stats.MaxWait = wait
+		}
+	}
+	return nil
+}
+
+// Stats vraća podatke o čekanju na ovo ograničenje
+func (b *TokenBucket) Stats() LimiterStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := b.stats
+	stats.PerMinute = b.limit.PerMinute
+	stats.Burst = b.limit.Burst
+	stats.TotalWaitSeconds = stats.TotalWait.Seconds()
+	stats.MaxWaitSeconds = stats.MaxWait.Seconds()
+	if stats.Waited > 0 {
+		stats.AvgWaitSeconds = stats.TotalWait.Seconds() / float64(stats.Waited)
+	}
+	return stats
+}
+
+// Ograničenja su zajednička za cijeli proces, kako bi i automatsko
+// ažuriranje i handleri trošili isti broj poziva prema izvoru.
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[string]*TokenBucket)
+)
+
+// limiterFor vraća ograničenje za izvor sa zadanim nazivom,
+// a kreira ga ako još ne postoji.
+func limiterFor(name string, limits map[string]RateLimit) *TokenBucket {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	if b, ok := limiters[name]; ok {
+		return b
+	}
+	limit, ok := limits[name]
+	if !ok {
+		limit, ok = DefaultRateLimits[name]
+	}
+	if !ok {
+		limit = RateLimit{PerMinute: 60, Burst: 1}
+	}
+	b := NewTokenBucket(limit)
+	limiters[name] = b
+	return b
+}
+
+// LimiterStatuses vraća podatke o čekanju za sva ograničenja
+func LimiterStatuses() []LimiterStats {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	statuses := []LimiterStats{}
+	for name, b := range limiters {
+		stats := b.Stats()
+		stats.Provider = name
+		statuses = append(statuses, stats)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Provider < statuses[j].Provider })
+	return statuses
+}
+
+// rateLimited je izvor prognoze čiji pozivi prolaze kroz ograničenje
+type rateLimited struct {
+	WeatherProvider
+	bucket *TokenBucket
+}
+
+// Forecast čeka na token pa tek onda poziva izvor
+func (r *rateLimited) Forecast(ctx context.Context, lat, lon string, start, end time.Time) ([]WeatherData, error) {
+	if err := r.bucket.Wait(ctx); err != nil {
+		return []WeatherData{}, err
+	}
+	return r.WeatherProvider.Forecast(ctx, lat, lon, start, end)
+}
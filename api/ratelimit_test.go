@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketBurst(t *testing.T) {
+	// Jedan token u minuti, pa se potrošeni tokeni u testu ne obnove
+	b := NewTokenBucket(RateLimit{PerMinute: 1, Burst: 3})
+
+	for i := 0; i < 3; i++ {
+		if err := b.Wait(context.Background()); err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+	}
+	stats := b.Stats()
+	if stats.Calls != 3 || stats.Waited != 0 || stats.Queued != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// Bez tokena poziv čeka dok se ctx ne prekine, a token se vraća
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	stats = b.Stats()
+	if stats.Cancelled != 1 || stats.Queued != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if b.tokens < -0.01 || b.tokens > 0.01 {
+		t.Errorf("tokens = %v, want the cancelled token returned", b.tokens)
+	}
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	// 1200 tokena u minuti je jedan token svakih 50 ms
+	b := NewTokenBucket(RateLimit{PerMinute: 1200, Burst: 1})
+
+	begin := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := b.Wait(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Prvi poziv prolazi odmah, a ostali čekaju svoj token
+	if elapsed := time.Since(begin); elapsed < 90*time.Millisecond {
+		t.Errorf("three calls took %v, want at least 100ms", elapsed)
+	}
+	stats := b.Stats()
+	if stats.Calls != 3 || stats.Waited != 2 || stats.MaxWait < 90*time.Millisecond {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestNewTokenBucketDefaults(t *testing.T) {
+	b := NewTokenBucket(RateLimit{})
+	if b.limit.Burst != 1 || b.limit.PerMinute != 60 {
+		t.Errorf("got %+v, want one call at a time, 60 per minute", b.limit)
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	p := &fakeProvider{}
+	r := &rateLimited{p, NewTokenBucket(RateLimit{PerMinute: 1, Burst: 1})}
+
+	start := time.Date(2030, 5, 4, 9, 0, 0, 0, time.UTC)
+	if _, err := r.Forecast(context.Background(), "45.81", "15.98", start, start); err != nil {
+		t.Fatal(err)
+	}
+
+	// Drugi poziv nema token pa izvor ne poziva
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := r.Forecast(ctx, "45.81", "15.98", start, start); err == nil {
+		t.Fatal("expected an error without a token")
+	}
+	if p.Calls() != 1 {
+		t.Errorf("got %d calls, want 1", p.Calls())
+	}
+}
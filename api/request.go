@@ -0,0 +1,520 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RaceRequest su podaci utrke iz zahtjeva za dodavanje ili ažuriranje.
+// JSON zahtjevi koriste engleske nazive polja, a forme hrvatske.
+type RaceRequest struct {
+	Name  string     `json:"name"`
+	Lat   Coordinate `json:"lat"`
+	Lon   Coordinate `json:"lon"`
+	Start string     `json:"start"`
+	End   string     `json:"end"`
+	// TZ je vremenska zona utrke, npr. Europe/Zagreb. Uz nju
+	// vrijeme početka i kraja može biti zadano bez zone.
+	TZ string `json:"tz"`
+
+	// rowErrors su greške retka CSV datoteke iz kojeg je utrka
+	// pročitana, npr. pogrešan broj stupaca. Uvoz takvu utrku
+	// označava neispravnom bez daljnje provjere.
+	rowErrors ValidationErrors
+}
+
+// Coordinate je koordinata iz JSON zahtjeva. Može biti zadana
+// kao broj ili kao string, a čuvamo je kao string kao i u bazi.
+type Coordinate string
+
+// UnmarshalJSON čita koordinatu zadanu kao broj ili kao string
+func (co *Coordinate) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*co = Coordinate(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return errors.New("koordinata mora biti broj")
+	}
+	*co = Coordinate(n.String())
+	return nil
+}
+
+// bindRaceRequest čita podatke utrke iz zahtjeva. Za Content-Type
+// application/json čita JSON tijelo, a inače polja forme naziv, lat,
+// lon, pocetak, kraj i tz, kako bi postojeći klijenti i dalje radili.
+func bindRaceRequest(c *gin.Context) (req RaceRequest, err error) {
+	if c.ContentType() == gin.MIMEJSON {
+		if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+			return req, ValidationError("neispravno JSON tijelo zahtjeva: " + err.Error())
+		}
+		return req, nil
+	}
+
+	req.Name = c.PostForm("naziv")
+	req.Lat = Coordinate(c.PostForm("lat"))
+	req.Lon = Coordinate(c.PostForm("lon"))
+	req.Start = c.PostForm("pocetak")
+	req.End = c.PostForm("kraj")
+	req.TZ = c.PostForm("tz")
+	return req, nil
+}
+
+// MIMEMergePatch je Content-Type JSON Merge Patch dokumenta (RFC 7396)
+const MIMEMergePatch = "application/merge-patch+json"
+
+// RacePatch je JSON Merge Patch dokument utrke. Sadrži samo polja
+// koja treba promijeniti, a null briše vrijednost polja.
+type RacePatch map[string]json.RawMessage
+
+// bindRacePatch čita JSON Merge Patch dokument iz tijela zahtjeva.
+// Prihvaća application/merge-patch+json i application/json.
+func bindRacePatch(c *gin.Context) (patch RacePatch, err error) {
+	if ct := c.ContentType(); ct != MIMEMergePatch && ct != gin.MIMEJSON {
+		return nil, ValidationError("Content-Type mora biti " + MIMEMergePatch)
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&patch); err != nil {
+		return nil, ValidationError("tijelo zahtjeva mora biti JSON objekt: " + err.Error())
+	}
+	return patch, nil
+}
+
+// Apply mijenja podatke utrke poljima iz patch dokumenta. Null briše
+// vrijednost, pa utrka bez tz ponovno koristi zonu lokacije, a obavezna
+// polja bez vrijednosti kasnije ne prolaze provjeru podataka.
+func (patch RacePatch) Apply(req *RaceRequest) (errs ValidationErrors) {
+	fields := map[string]*string{
+		"name":  &req.Name,
+		"start": &req.Start,
+		"end":   &req.End,
+		"tz":    &req.TZ,
+	}
+	coordinates := map[string]*Coordinate{
+		"lat": &req.Lat,
+		"lon": &req.Lon,
+	}
+
+	// Polja čitamo abecedno kako bi greške uvijek bile istim redom
+	names := make([]string, 0, len(patch))
+	for name := range patch {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		raw := patch[name]
+		if string(raw) == "null" {
+			raw = []byte(`""`)
+		}
+		var err error
+		if dst, ok := fields[name]; ok {
+			err = json.Unmarshal(raw, dst)
+		} else if dst, ok := coordinates[name]; ok {
+			err = json.Unmarshal(raw, dst)
+		} else {
+			errs = append(errs, FieldError{name, CodeNotAllowed, "polje utrke ne postoji"})
+			continue
+		}
+		if err != nil {
+			errs = append(errs, FieldError{name, CodeInvalidType, "neispravan tip vrijednosti polja"})
+		}
+	}
+	return errs
+}
+
+// Has javlja da li patch dokument mijenja polje
+func (patch RacePatch) Has(name string) bool {
+	_, ok := patch[name]
+	return ok
+}
+
+// Zadana i najveća veličina stranice popisa utrka
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// Vrijednosti parametara status i sort za popis utrka
+var (
+	raceStatuses = []string{"upcoming", "finished"}
+	raceSorts    = []string{"start", "-start", "name", "-name"}
+)
+
+// bindRaceQuery čita filtere, poredak i stranicu popisa utrka iz parametara
+// zahtjeva: limit, offset, starts_after, ends_before, status, name, bbox i sort.
+// Ako limit nije zadan, koristi se defaultLimit.
+func bindRaceQuery(c *gin.Context, defaultLimit int) (q RaceQuery, err error) {
+
+	errs := Validate(
+		Field{"limit", c.Query("limit"), Optional(IntRange(1, MaxPageSize))},
+		Field{"offset", c.Query("offset"), Optional(IntRange(0, 1<<31-1))},
+		Field{"starts_after", c.Query("starts_after"), Optional(DateTime(nil))},
+		Field{"ends_before", c.Query("ends_before"), Optional(DateTime(nil))},
+		Field{"status", c.Query("status"), Optional(OneOf(raceStatuses...))},
+		Field{"name", c.Query("name"), Optional(MaxLength(MaxRaceNameLength))},
+		Field{"bbox", c.Query("bbox"), Optional(bboxRule)},
+		Field{"sort", c.Query("sort"), Optional(OneOf(raceSorts...))},
+	)
+	if len(errs) > 0 {
+		return q, ValidationError("parametri popisa utrka nisu ispravni", errs...)
+	}
+
+	// Pravila su prošla pa su sve zadane vrijednosti ispravne
+	q.Limit = defaultLimit
+	if v := c.Query("limit"); v != "" {
+		q.Limit, _ = strconv.Atoi(v)
+	}
+	q.Offset, _ = strconv.Atoi(c.Query("offset"))
+	if v := c.Query("starts_after"); v != "" {
+		q.StartsAfter, _ = parseRaceTime(v, nil)
+	}
+	if v := c.Query("ends_before"); v != "" {
+		q.EndsBefore, _ = parseRaceTime(v, nil)
+	}
+	if v := c.Query("bbox"); v != "" {
+		q.BBox, _ = parseBBox(v)
+	}
+	q.Status = c.Query("status")
+	q.Name = c.Query("name")
+	q.Sort = c.Query("sort")
+	return q, nil
+}
+
+// bboxRule zahtijeva da je polje ispravno područje min_lon,min_lat,max_lon,max_lat
+func bboxRule(field, value string) *FieldError {
+	if _, err := parseBBox(value); err != nil {
+		return &FieldError{field, CodeInvalidBBox, err.Error()}
+	}
+	return nil
+}
+
+// parseBBox čita područje zadano kao min_lon,min_lat,max_lon,max_lat
+func parseBBox(value string) (*BBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, errors.New("područje mora biti zadano kao min_lon,min_lat,max_lon,max_lat")
+	}
+	var v [4]float64
+	for i, p := range parts {
+		f, err := parseDecimal(strings.TrimSpace(p))
+		if err != nil {
+			return nil, fmt.Errorf("%q nije broj", p)
+		}
+		v[i] = f
+	}
+	b := &BBox{MinLon: v[0], MinLat: v[1], MaxLon: v[2], MaxLat: v[3]}
+	if b.MinLat < -90 || b.MaxLat > 90 || b.MinLat > b.MaxLat {
+		return nil, errors.New("geografska širina mora biti između -90 i 90, a min_lat manji od max_lat")
+	}
+	if b.MinLon < -180 || b.MaxLon > 180 || b.MinLon > 180 || b.MaxLon < -180 {
+		return nil, errors.New("geografska dužina mora biti između -180 i 180")
+	}
+	return b, nil
+}
+
+// DefaultRadiusKm je polumjer pretrage utrka u blizini ako nije zadan
+const DefaultRadiusKm = 50
+
+// bindNearbyQuery čita parametre pretrage utrka u blizini točke:
+// lat, lon, radius_km, status i limit
+func bindNearbyQuery(c *gin.Context, defaultLimit int) (q NearbyQuery, err error) {
+
+	errs := Validate(
+		Field{"lat", c.Query("lat"), []Rule{Required(), FloatRange(-90, 90)}},
+		Field{"lon", c.Query("lon"), []Rule{Required(), FloatRange(-180, 180)}},
+		Field{"radius_km", c.Query("radius_km"), Optional(FloatRange(0, MaxRadiusKm))},
+		Field{"status", c.Query("status"), Optional(OneOf(raceStatuses...))},
+		Field{"limit", c.Query("limit"), Optional(IntRange(1, MaxPageSize))},
+	)
+	if len(errs) > 0 {
+		return q, ValidationError("parametri pretrage utrka nisu ispravni", errs...)
+	}
+
+	// Pravila su prošla pa su sve zadane vrijednosti ispravne
+	q.Point, _ = parsePoint(c.Query("lat"), c.Query("lon"))
+	q.RadiusKm = DefaultRadiusKm
+	if v := c.Query("radius_km"); v != "" {
+		q.RadiusKm, _ = strconv.ParseFloat(v, 64)
+	}
+	q.Status = c.Query("status")
+	q.Limit = defaultLimit
+	if v := c.Query("limit"); v != "" {
+		q.Limit, _ = strconv.Atoi(v)
+	}
+	return q, nil
+}
+
+// bindWithinQuery čita parametre pretrage utrka unutar područja: polygon
+// ili bbox, status, limit te lat i lon točke od koje računamo udaljenost.
+// Ako točka nije zadana, udaljenost računamo od središta područja.
+func bindWithinQuery(c *gin.Context, defaultLimit int) (q NearbyQuery, err error) {
+
+	lat, lon := c.Query("lat"), c.Query("lon")
+	errs := Validate(
+		Field{"polygon", c.Query("polygon"), Optional(polygonRule)},
+		Field{"bbox", c.Query("bbox"), Optional(bboxRule)},
+		Field{"lat", lat, Optional(FloatRange(-90, 90))},
+		Field{"lon", lon, Optional(FloatRange(-180, 180))},
+		Field{"status", c.Query("status"), Optional(OneOf(raceStatuses...))},
+		Field{"limit", c.Query("limit"), Optional(IntRange(1, MaxPageSize))},
+	)
+	if c.Query("polygon") == "" && c.Query("bbox") == "" {
+		errs = append(errs, FieldError{"polygon", CodeRequired, "potrebno je zadati polygon ili bbox"})
+	}
+	if (lat == "") != (lon == "") {
+		errs = append(errs, FieldError{"lat", CodeRequired, "lat i lon moraju biti zadani zajedno"})
+	}
+	if len(errs) > 0 {
+		return q, ValidationError("parametri pretrage utrka nisu ispravni", errs...)
+	}
+
+	// Pravila su prošla pa su sve zadane vrijednosti ispravne
+	q.Area = &Area{}
+	if v := c.Query("polygon"); v != "" {
+		q.Area.Polygon, _ = parsePolygon(v)
+		q.Area.BBox = q.Area.Polygon.BBox()
+	}
+	if v := c.Query("bbox"); v != "" {
+		// Uz zadan mnogokut područje je presjek mnogokuta i pravokutnika
+		q.Area.BBox, _ = parseBBox(v)
+	}
+	q.Point = q.Area.BBox.Center()
+	if lat != "" {
+		q.Point, _ = parsePoint(lat, lon)
+	}
+	q.Status = c.Query("status")
+	q.Limit = defaultLimit
+	if v := c.Query("limit"); v != "" {
+		q.Limit, _ = strconv.Atoi(v)
+	}
+	return q, nil
+}
+
+// bindPointForecast čita parametre prognoze za točku: lat, lon, from i to
+func bindPointForecast(c *gin.Context) (p Point, from, to time.Time, err error) {
+	p, from, to, errs := parsePointForecast(c.Query("lat"), c.Query("lon"), c.Query("from"), c.Query("to"))
+	if len(errs) > 0 {
+		return p, from, to, ValidationError("parametri prognoze nisu ispravni", errs...)
+	}
+	return p, from, to, nil
+}
+
+// parsePointForecast provjerava i čita točku i interval prognoze za točku.
+// Prazan from ili to znači da interval s te strane nije ograničen.
+func parsePointForecast(lat, lon, fromValue, toValue string) (p Point, from, to time.Time, errs ValidationErrors) {
+
+	errs = Validate(
+		Field{"lat", lat, []Rule{Required(), FloatRange(-90, 90)}},
+		Field{"lon", lon, []Rule{Required(), FloatRange(-180, 180)}},
+		Field{"from", fromValue, Optional(DateTime(nil))},
+		Field{"to", toValue, Optional(DateTime(nil))},
+	)
+	if len(errs) > 0 {
+		return p, from, to, errs
+	}
+
+	// Pravila su prošla pa su sve zadane vrijednosti ispravne
+	p, _ = parsePoint(lat, lon)
+	if fromValue != "" {
+		from, _ = parseRaceTime(fromValue, nil)
+	}
+	if toValue != "" {
+		to, _ = parseRaceTime(toValue, nil)
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		errs = append(errs, FieldError{"to", CodeEndBeforeStart, "to mora biti nakon from"})
+	}
+	return p, from, to, errs
+}
+
+// MaxBatchSize je najveći broj točaka u jednom zahtjevu za prognoze
+const MaxBatchSize = 50
+
+// BatchItem je jedna točka zahtjeva za prognoze više točaka
+type BatchItem struct {
+	Lat  Coordinate `json:"lat"`
+	Lon  Coordinate `json:"lon"`
+	From string     `json:"from"`
+	To   string     `json:"to"`
+}
+
+// bindBatchRequest čita točke iz JSON tijela {"items": [...]}
+func bindBatchRequest(c *gin.Context) ([]BatchItem, error) {
+	var req struct {
+		Items []BatchItem `json:"items"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		return nil, ValidationError("neispravno JSON tijelo zahtjeva: " + err.Error())
+	}
+	if len(req.Items) == 0 || len(req.Items) > MaxBatchSize {
+		message := fmt.Sprintf("zahtjev mora imati između 1 i %d točaka", MaxBatchSize)
+		return nil, ValidationError(message, FieldError{"items", CodeOutOfRange, message})
+	}
+	return req.Items, nil
+}
+
+// MaxImportRows je najveći broj utrka u jednom uvozu
+const MaxImportRows = 1000
+
+// MIMECSV je Content-Type CSV datoteke s utrkama
+const MIMECSV = "text/csv"
+
+// importModes su načini uvoza utrka
+var importModes = []string{ImportAtomic, ImportBestEffort}
+
+// bindImport čita utrke za uvoz iz CSV datoteke ili JSON niza te način
+// uvoza iz parametara mode (atomic ili best_effort) i dry_run.
+func bindImport(c *gin.Context) (reqs []RaceRequest, mode string, dryRun bool, err error) {
+
+	errs := Validate(
+		Field{"mode", c.Query("mode"), Optional(OneOf(importModes...))},
+		Field{"dry_run", c.Query("dry_run"), Optional(OneOf("true", "false"))},
+	)
+	if len(errs) > 0 {
+		return nil, mode, dryRun, ValidationError("parametri uvoza nisu ispravni", errs...)
+	}
+	mode = c.DefaultQuery("mode", ImportAtomic)
+	dryRun = c.Query("dry_run") == "true"
+
+	switch c.ContentType() {
+	case MIMECSV, "application/csv":
+		reqs, err = parseRaceCSV(c.Request.Body)
+	case gin.MIMEJSON:
+		if err := json.NewDecoder(c.Request.Body).Decode(&reqs); err != nil {
+			return nil, mode, dryRun, ValidationError("tijelo zahtjeva mora biti JSON niz utrka: " + err.Error())
+		}
+	default:
+		return nil, mode, dryRun, ValidationError("Content-Type mora biti " + MIMECSV + " ili " + gin.MIMEJSON)
+	}
+	if err != nil {
+		return nil, mode, dryRun, err
+	}
+
+	if len(reqs) == 0 || len(reqs) > MaxImportRows {
+		message := fmt.Sprintf("uvoz mora imati između 1 i %d utrka", MaxImportRows)
+		return nil, mode, dryRun, ValidationError(message, FieldError{"rows", CodeOutOfRange, message})
+	}
+	return reqs, mode, dryRun, nil
+}
+
+// csvColumns su nazivi stupaca CSV datoteke, engleski kao u JSON
+// zahtjevima i hrvatski kao u formi, s poljem utrke u koje se čitaju
+var csvColumns = map[string]string{
+	"name":    "name",
+	"naziv":   "name",
+	"lat":     "lat",
+	"lon":     "lon",
+	"start":   "start",
+	"pocetak": "start",
+	"end":     "end",
+	"kraj":    "end",
+	"tz":      "tz",
+}
+
+// csvField vraća polje utrke u koje se čita stupac CSV datoteke
+func csvField(req *RaceRequest, field string) *string {
+	switch field {
+	case "name":
+		return &req.Name
+	case "lat":
+		return (*string)(&req.Lat)
+	case "lon":
+		return (*string)(&req.Lon)
+	case "start":
+		return &req.Start
+	case "end":
+		return &req.End
+	default:
+		return &req.TZ
+	}
+}
+
+// parseRaceCSV čita utrke iz CSV datoteke sa zaglavljem. Stupci mogu biti
+// bilo kojim redom, a nepoznati stupci se zanemaruju. Osim zareza
+// prihvaća i točku-zarez, koju koriste tablični kalkulatori u Hrvatskoj.
+func parseRaceCSV(body io.Reader) ([]RaceRequest, error) {
+
+	br := bufio.NewReader(body)
+	// Excel na početak UTF-8 datoteke stavlja BOM
+	if bom, _ := br.Peek(3); bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		br.Discard(3)
+	}
+
+	// Razdjelnik prepoznajemo po zaglavlju
+	r := csv.NewReader(br)
+	header, _ := br.Peek(4096)
+	if i := bytes.IndexByte(header, '\n'); i >= 0 {
+		header = header[:i]
+	}
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		r.Comma = ';'
+	}
+	r.TrimLeadingSpace = true
+	// Redak s pogrešnim brojem stupaca prijavljujemo samo za taj redak
+	r.FieldsPerRecord = -1
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, ValidationError("neispravna CSV datoteka: " + err.Error())
+	}
+	if len(records) == 0 {
+		return nil, ValidationError("CSV datoteka nema zaglavlje")
+	}
+
+	columns := make([]string, len(records[0]))
+	found := make(map[string]bool)
+	for i, name := range records[0] {
+		columns[i] = csvColumns[strings.ToLower(strings.TrimSpace(name))]
+		found[columns[i]] = true
+	}
+	var errs ValidationErrors
+	for _, field := range []string{"name", "lat", "lon", "start", "end"} {
+		if !found[field] {
+			errs = append(errs, FieldError{field, CodeRequired, "CSV datoteka nema stupac " + field})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, ValidationError("CSV datoteka nema sve potrebne stupce", errs...)
+	}
+
+	reqs := make([]RaceRequest, 0, len(records)-1)
+	for _, record := range records[1:] {
+		var req RaceRequest
+		if len(record) != len(columns) {
+			message := fmt.Sprintf("redak ima %d stupaca, a zaglavlje %d", len(record), len(columns))
+			req.rowErrors = ValidationErrors{{"row", CodeFieldCount, message}}
+		}
+		for i, value := range record {
+			// Stupce viška u neispravnom retku ne čitamo
+			if i >= len(columns) {
+				break
+			}
+			value = strings.TrimSpace(value)
+			// Uz točku-zarez decimalni brojevi obično imaju decimalni zarez
+			if r.Comma == ';' && (columns[i] == "lat" || columns[i] == "lon") {
+				value = strings.Replace(value, ",", ".", 1)
+			}
+			if columns[i] != "" {
+				*csvField(&req, columns[i]) = value
+			}
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
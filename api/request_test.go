@@ -0,0 +1,269 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newPostContext kreira gin kontekst za POST zahtjev sa zadanim tijelom
+func newPostContext(contentType, body string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/race", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", contentType)
+	return c
+}
+
+func TestRacePatchApply(t *testing.T) {
+	req := RaceRequest{Name: "Maraton", Lat: "45.81", Lon: "15.98", Start: "a", End: "b", TZ: "Europe/Zagreb"}
+
+	var patch RacePatch
+	if err := json.Unmarshal([]byte(`{"name": "Polumaraton", "lat": 45.5, "lon": "16.1", "tz": null}`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	if errs := patch.Apply(&req); len(errs) > 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	want := RaceRequest{Name: "Polumaraton", Lat: "45.5", Lon: "16.1", Start: "a", End: "b"}
+	if !reflect.DeepEqual(req, want) {
+		t.Errorf("got %+v, want %+v", req, want)
+	}
+	if !patch.Has("tz") || patch.Has("start") {
+		t.Error("Has must report only the fields in the patch")
+	}
+
+	// Greške su poredane po nazivu polja
+	if err := json.Unmarshal([]byte(`{"start": 5, "id": 3, "name": ["a"], "lat": true}`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	errs := patch.Apply(&req)
+	var got []string
+	for _, fe := range errs {
+		got = append(got, fe.Field+":"+fe.Code)
+	}
+	if strings.Join(got, ",") != "id:not_allowed,lat:invalid_type,name:invalid_type,start:invalid_type" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestCoordinateUnmarshal(t *testing.T) {
+	var req RaceRequest
+	if err := json.Unmarshal([]byte(`{"lat": 45.815, "lon": "15.98"}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Lat != "45.815" || req.Lon != "15.98" {
+		t.Errorf("got %q and %q", req.Lat, req.Lon)
+	}
+	if err := json.Unmarshal([]byte(`{"lat": {}}`), &req); err == nil {
+		t.Error("expected an error for an object coordinate")
+	}
+}
+
+func TestBindRaceRequest(t *testing.T) {
+	want := RaceRequest{Name: "Maraton", Lat: "45.81", Lon: "15.98", Start: "2030-05-04T08:00:00Z", End: "2030-05-04T14:00:00Z"}
+
+	// JSON tijelo ima engleske nazive polja
+	c := newPostContext("application/json; charset=utf-8",
+		`{"name": "Maraton", "lat": 45.81, "lon": "15.98", "start": "2030-05-04T08:00:00Z", "end": "2030-05-04T14:00:00Z"}`)
+	if req, err := bindRaceRequest(c); err != nil || !reflect.DeepEqual(req, want) {
+		t.Errorf("JSON: got %+v, %v", req, err)
+	}
+
+	// Forma ima hrvatske nazive polja
+	c = newPostContext("application/x-www-form-urlencoded",
+		"naziv=Maraton&lat=45.81&lon=15.98&pocetak=2030-05-04T08:00:00Z&kraj=2030-05-04T14:00:00Z&tz=Europe/Zagreb")
+	want.TZ = "Europe/Zagreb"
+	if req, err := bindRaceRequest(c); err != nil || !reflect.DeepEqual(req, want) {
+		t.Errorf("form: got %+v, %v", req, err)
+	}
+
+	c = newPostContext("application/json", `{"name": "Maraton", "lat": [45]}`)
+	if _, err := bindRaceRequest(c); err == nil {
+		t.Error("expected an error for an invalid JSON body")
+	}
+}
+
+func TestParseBBox(t *testing.T) {
+	b, err := parseBBox("13.5, 42.4, 19.4, 46.6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *b != (BBox{MinLon: 13.5, MinLat: 42.4, MaxLon: 19.4, MaxLat: 46.6}) {
+		t.Errorf("got %+v", b)
+	}
+
+	// Pravokutnik smije prelaziti 180. meridijan
+	if _, err := parseBBox("170,-10,-170,10"); err != nil {
+		t.Errorf("bbox across the antimeridian: %v", err)
+	}
+	for _, value := range []string{"13.5,42.4,19.4", "13.5,46.6,19.4,42.4", "13.5,42.4,19.4,91", "NaN,42.4,19.4,46.6"} {
+		if _, err := parseBBox(value); err == nil {
+			t.Errorf("parseBBox(%q) accepted an invalid bbox", value)
+		}
+	}
+}
+
+func TestBindRaceQuery(t *testing.T) {
+	c, _ := newTestContext("/races?offset=20&status=upcoming&sort=-start&bbox=13.5,42.4,19.4,46.6&starts_after=2030-05-04T10:00:00%2B02:00")
+	q, err := bindRaceQuery(c, DefaultPageSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Limit != DefaultPageSize || q.Offset != 20 || q.Status != "upcoming" || q.Sort != "-start" ||
+		q.BBox == nil || !q.StartsAfter.Equal(time.Date(2030, 5, 4, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("got %+v", q)
+	}
+
+	c, _ = newTestContext("/races?limit=0&status=sutra&sort=lat&bbox=1,2")
+	_, err = bindRaceQuery(c, DefaultPageSize)
+	if e := asError(err); e.Kind != KindValidation || len(e.Fields) != 4 {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestParsePointForecast(t *testing.T) {
+	p, from, to, errs := parsePointForecast("45.81", "15.98", "2030-05-04T10:00:00+02:00", "")
+	if len(errs) > 0 || p != (Point{45.81, 15.98}) || !from.Equal(time.Date(2030, 5, 4, 8, 0, 0, 0, time.UTC)) || !to.IsZero() {
+		t.Errorf("got %v, %v, %v, %v", p, from, to, errs)
+	}
+
+	_, _, _, errs = parsePointForecast("45.81", "15.98", "2030-05-04T10:00:00Z", "2030-05-04T09:00:00Z")
+	if len(errs) != 1 || errs[0].Field != "to" || errs[0].Code != CodeEndBeforeStart {
+		t.Errorf("to before from: got %v", errs)
+	}
+	if _, _, _, errs = parsePointForecast("", "181", "sutra", ""); len(errs) != 3 {
+		t.Errorf("got %v", errs)
+	}
+}
+
+func TestBindBatchRequest(t *testing.T) {
+	c := newPostContext("application/json", `{"items": [{"lat": 45.81, "lon": "15.98", "to": "2030-05-04T10:00:00Z"}]}`)
+	items, err := bindBatchRequest(c)
+	if err != nil || len(items) != 1 || items[0] != (BatchItem{Lat: "45.81", Lon: "15.98", To: "2030-05-04T10:00:00Z"}) {
+		t.Errorf("got %v, %v", items, err)
+	}
+
+	tooMany := `{"items": [` + strings.Repeat(`{"lat": 1, "lon": 1},`, MaxBatchSize) + `{"lat": 1, "lon": 1}]}`
+	for _, body := range []string{`{"items": []}`, tooMany, `{"items": `} {
+		if _, err := bindBatchRequest(newPostContext("application/json", body)); asError(err).Kind != KindValidation {
+			t.Errorf("got %v, want a validation error", err)
+		}
+	}
+}
+
+func TestParseRaceCSV(t *testing.T) {
+	body := "\xef\xbb\xbfNaziv,lat,lon,pocetak,kraj,napomena\n" +
+		"Maraton,45.81,15.98,2030-05-04T08:00:00Z,2030-05-04T14:00:00Z,x\n" +
+		"\"Utrka, duga\", 43.5 ,16.44,2030-06-01T08:00,2030-06-01T12:00,\n"
+	reqs, err := parseRaceCSV(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RaceRequest{
+		{Name: "Maraton", Lat: "45.81", Lon: "15.98", Start: "2030-05-04T08:00:00Z", End: "2030-05-04T14:00:00Z"},
+		{Name: "Utrka, duga", Lat: "43.5", Lon: "16.44", Start: "2030-06-01T08:00", End: "2030-06-01T12:00"},
+	}
+	if len(reqs) != len(want) {
+		t.Fatalf("got %d races, want %d", len(reqs), len(want))
+	}
+	for i := range want {
+		if reqs[i].Name != want[i].Name || reqs[i].Lat != want[i].Lat || reqs[i].Lon != want[i].Lon ||
+			reqs[i].Start != want[i].Start || reqs[i].End != want[i].End || len(reqs[i].rowErrors) > 0 {
+			t.Errorf("row %d: got %+v, want %+v", i+1, reqs[i], want[i])
+		}
+	}
+}
+
+func TestParseRaceCSVSemicolon(t *testing.T) {
+	body := "name;lat;lon;start;end;tz\n" +
+		"Maraton;45,81;15,98;2030-05-04T08:00;2030-05-04T14:00;Europe/Zagreb\n"
+	reqs, err := parseRaceCSV(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != 1 || reqs[0].Lat != "45.81" || reqs[0].Lon != "15.98" || reqs[0].TZ != "Europe/Zagreb" {
+		t.Errorf("got %+v", reqs)
+	}
+}
+
+func TestParseRaceCSVRaggedRows(t *testing.T) {
+	body := "name,lat,lon,start,end\n" +
+		"Kratki,45.81,15.98\n" +
+		"Ispravan,45.81,15.98,2030-05-04T08:00:00Z,2030-05-04T14:00:00Z\n" +
+		"Dugi,45.81,15.98,2030-05-04T08:00:00Z,2030-05-04T14:00:00Z,višak\n"
+	reqs, err := parseRaceCSV(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != 3 {
+		t.Fatalf("got %d races, want 3", len(reqs))
+	}
+	for i, wantErr := range []bool{true, false, true} {
+		if got := len(reqs[i].rowErrors) > 0; got != wantErr {
+			t.Errorf("row %d: got errors %v", i+1, reqs[i].rowErrors)
+		}
+	}
+	if reqs[0].Name != "Kratki" || reqs[0].rowErrors[0].Code != CodeFieldCount {
+		t.Errorf("unexpected short row %+v", reqs[0])
+	}
+}
+
+func TestParseRaceCSVErrors(t *testing.T) {
+	tests := []struct {
+		body string
+		code string
+	}{
+		{"", ""},
+		{"name,lat,start,end\nMaraton,45.81,a,b\n", CodeRequired},
+		{"name,lat,lon,start,end\n\"Maraton,45.81,15.98,a,b\n", ""},
+	}
+	for _, tt := range tests {
+		_, err := parseRaceCSV(strings.NewReader(tt.body))
+		if err == nil {
+			t.Errorf("parseRaceCSV(%q) accepted an invalid file", tt.body)
+			continue
+		}
+		e := asError(err)
+		if e.Kind != KindValidation {
+			t.Errorf("parseRaceCSV(%q): got kind %v, want validation", tt.body, e.Kind)
+		}
+		if tt.code != "" && (len(e.Fields) != 1 || e.Fields[0].Code != tt.code || e.Fields[0].Field != "lon") {
+			t.Errorf("parseRaceCSV(%q): got fields %v", tt.body, e.Fields)
+		}
+	}
+}
+
+func TestBindImport(t *testing.T) {
+	c := newPostContext("application/json", `[{"name": "Maraton", "lat": 45.81, "lon": 15.98, "start": "a", "end": "b"}]`)
+	c.Request.URL.RawQuery = "mode=best_effort&dry_run=true"
+	reqs, mode, dryRun, err := bindImport(c)
+	if err != nil || len(reqs) != 1 || reqs[0].Name != "Maraton" || reqs[0].Lat != "45.81" ||
+		mode != ImportBestEffort || !dryRun {
+		t.Errorf("got %v, %s, %v, %v", reqs, mode, dryRun, err)
+	}
+
+	c = newPostContext("text/csv", "name,lat,lon,start,end\nMaraton,45.81,15.98,a,b\n")
+	if reqs, mode, dryRun, err := bindImport(c); err != nil || len(reqs) != 1 || mode != ImportAtomic || dryRun {
+		t.Errorf("csv: got %v, %s, %v, %v", reqs, mode, dryRun, err)
+	}
+
+	for _, tt := range []struct{ contentType, body, query string }{
+		{"application/json", `[]`, ""},
+		{"application/json", `{"name": "Maraton"}`, ""},
+		{"text/plain", "Maraton", ""},
+		{"application/json", `[{"name": "Maraton"}]`, "mode=sve"},
+	} {
+		c := newPostContext(tt.contentType, tt.body)
+		c.Request.URL.RawQuery = tt.query
+		if _, _, _, err := bindImport(c); asError(err).Kind != KindValidation {
+			t.Errorf("bindImport(%s %q): got %v, want a validation error", tt.contentType, tt.body, err)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package api
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BatchConcurrency je najveći broj istovremenih dohvata prognoza za jedan
+// zahtjev s više točaka. Pozivi i dalje prolaze kroz ograničenje izvora,
+// a ovo samo sprječava da jedan zahtjev zauzme sve pozive odjednom.
+const BatchConcurrency = 4
+
+// RaceService je sloj zajednički svim verzijama API-ja. Handleri samo
+// čitaju zahtjev i oblikuju odgovor, a provjera podataka, rad s bazom
+// i dohvat prognoza su ovdje, kako se verzije ne bi ponašale različito.
+type RaceService struct {
+	Provider WeatherProvider
+	// Cache pamti prognoze za točke koje nisu utrke
+	Cache *ForecastCache
+}
+
+// NewRaceService kreira servis koji prognoze dohvaća preko zadanog izvora,
+// a prognoze za točke pamti cacheTTL vremena
+func NewRaceService(provider WeatherProvider, cacheTTL time.Duration) *RaceService {
+	return &RaceService{
+		Provider: provider,
+		Cache:    NewForecastCache(provider, cacheTTL),
+	}
+}
+
+// List vraća utrke koje odgovaraju upitu i ukupan broj takvih utrka
+func (s *RaceService) List(q RaceQuery) ([]Race, int, error) {
+	races, total, err := QueryRaces(q)
+	if err != nil {
+		return races, total, InternalError("neuspjelo dohvaćanje utrka", err)
+	}
+	return races, total, nil
+}
+
+// Nearby vraća utrke koje odgovaraju pretrazi, poredane po udaljenosti
+// od tražene točke. Baza vraća utrke iz pravokutnika oko područja
+// pretrage, a udaljenost i pripadnost području provjeravamo ovdje.
+func (s *RaceService) Nearby(q NearbyQuery) ([]NearbyRace, error) {
+
+	races, _, err := QueryRaces(RaceQuery{Status: q.Status, BBox: q.bbox()})
+	if err != nil {
+		return nil, InternalError("neuspjelo dohvaćanje utrka", err)
+	}
+
+	nearby := []NearbyRace{}
+	for _, r := range races {
+		// Koordinate su u bazi brojevi pa su uvijek ispravne
+		p, _ := parsePoint(r.Lat, r.Lon)
+		if q.Area != nil && !q.Area.Contains(p) {
+			continue
+		}
+		d := haversineKm(q.Point, p)
+		if q.RadiusKm > 0 && d > q.RadiusKm {
+			continue
+		}
+		nearby = append(nearby, NearbyRace{Race: r, DistanceKm: math.Round(d*1000) / 1000})
+	}
+
+	sort.SliceStable(nearby, func(i, j int) bool {
+		return nearby[i].DistanceKm < nearby[j].DistanceKm
+	})
+	if q.Limit > 0 && len(nearby) > q.Limit {
+		nearby = nearby[:q.Limit]
+	}
+	return nearby, nil
+}
+
+// Get vraća utrku s određenim id-om
+func (s *RaceService) Get(id int64) (Race, error) {
+	return GetRace(id)
+}
+
+// Create provjerava i dodaje novu utrku te vraća njen id.
+// Prognoze za novu utrku dohvaća u pozadini.
+func (s *RaceService) Create(req RaceRequest) (int64, error) {
+
+	start, end, errs := ValidateRace(req)
+	if len(errs) > 0 {
+		return 0, ValidationError("podaci utrke nisu ispravni", errs...)
+	}
+
+	lat, lon := string(req.Lat), string(req.Lon)
+	raceID, locID, err := CreateRace(req.Name, lat, lon, req.TZ, start, end)
+	if err != nil {
+		return 0, err
+	}
+
+	go s.refreshForecasts(locID, lat, lon, start, end)
+	return raceID, nil
+}
+
+// Update provjerava podatke i ažurira utrku. Vraća false ako su svi podaci
+// isti kao prije. Ako su se promijenili lokacija ili vrijeme utrke,
+// prognoze dohvaća ponovno u pozadini.
+func (s *RaceService) Update(id int64, req RaceRequest) (changed bool, err error) {
+
+	start, end, errs := ValidateRace(req)
+	if len(errs) > 0 {
+		return false, ValidationError("podaci utrke nisu ispravni", errs...)
+	}
+	return s.update(id, req, start, end)
+}
+
+// Patch djelomično ažurira utrku prema JSON Merge Patch dokumentu.
+// Polja kojih nema u dokumentu ostaju ista, a prognoze se ponovno
+// dohvaćaju samo ako se promijenila lokacija ili vrijeme utrke.
+func (s *RaceService) Patch(id int64, patch RacePatch) (changed bool, err error) {
+
+	race, err := GetRace(id)
+	if err != nil {
+		return false, err
+	}
+	req := RaceRequest{
+		Name:  race.Name,
+		Lat:   Coordinate(race.Lat),
+		Lon:   Coordinate(race.Lon),
+		Start: race.Begin,
+		End:   race.End,
+		TZ:    race.RaceZone,
+	}
+	if errs := patch.Apply(&req); len(errs) > 0 {
+		return false, ValidationError("podaci utrke nisu ispravni", errs...)
+	}
+
+	// Utrka koja je već počela može promijeniti npr. naziv, pa
+	// vrijeme u prošlosti odbijamo samo ako ga patch mijenja.
+	start, end, all := ValidateRace(req)
+	var errs ValidationErrors
+	for _, fe := range all {
+		if fe.Code != CodeInPast || patch.Has(fe.Field) {
+			errs = append(errs, fe)
+		}
+	}
+	if len(errs) > 0 {
+		return false, ValidationError("podaci utrke nisu ispravni", errs...)
+	}
+	return s.update(id, req, start, end)
+}
+
+// update sprema provjerene podatke utrke i, ako su se promijenili
+// lokacija ili vrijeme utrke, ponovno dohvaća prognoze u pozadini.
+func (s *RaceService) update(id int64, req RaceRequest, start, end time.Time) (changed bool, err error) {
+
+	lat, lon := string(req.Lat), string(req.Lon)
+	update, err := UpdateRace(id, req.Name, lat, lon, req.TZ, start, end)
+	if err != nil {
+		return false, err
+	}
+
+	// Funkcija update_race vraća 0 i za nepostojeću utrku i kada
+	// su svi podaci isti, -1 ako se promijenio samo naziv ili zona,
+	// a inače id lokacije za koju treba dohvatiti prognoze.
+	switch update {
+	case 0:
+		if _, err := GetRace(id); err != nil {
+			return false, err
+		}
+		return false, nil
+	case RaceRenamed:
+		return true, nil
+	default:
+		go s.refreshForecasts(update, lat, lon, start, end)
+		return true, nil
+	}
+}
+
+// Načini uvoza utrka: sve ili ništa, ili dodavanje svih ispravnih utrka
+const (
+	ImportAtomic     = "atomic"
+	ImportBestEffort = "best_effort"
+)
+
+// Import provjerava i dodaje utrke iz uvoza. Uz dryRun samo provjerava
+// utrke. U načinu ImportAtomic ne dodaje nijednu utrku ako bilo koja nije
+// ispravna, a u načinu ImportBestEffort dodaje sve ispravne. Nakon dodavanja
+// prognoze dohvaća u pozadini, jednom za svaku lokaciju.
+func (s *RaceService) Import(reqs []RaceRequest, mode string, dryRun bool) (ImportReport, error) {
+
+	report := ImportReport{Mode: mode, DryRun: dryRun, Total: len(reqs), Rows: make([]ImportResult, len(reqs))}
+	var races []NewRace
+	var rows []int
+	for i, req := range reqs {
+		report.Rows[i] = ImportResult{Row: i + 1, Name: req.Name}
+		errs := req.rowErrors
+		var start, end time.Time
+		if len(errs) == 0 {
+			start, end, errs = ValidateRace(req)
+		}
+		if len(errs) > 0 {
+			report.Rows[i].Status, report.Rows[i].Errors = ImportInvalid, errs
+			report.Invalid++
+			continue
+		}
+		report.Rows[i].Status = ImportValid
+		report.Valid++
+		races = append(races, NewRace{req.Name, string(req.Lat), string(req.Lon), req.TZ, start, end})
+		rows = append(rows, i)
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	if mode == ImportAtomic && report.Invalid > 0 {
+		for _, i := range rows {
+			report.Rows[i].Status = ImportSkipped
+		}
+		return report, nil
+	}
+
+	var raceIDs, locIDs []int64
+	if mode == ImportAtomic {
+		var err error
+		if raceIDs, locIDs, err = CreateRaces(races); err != nil {
+			return report, err
+		}
+	} else {
+		raceIDs, locIDs = make([]int64, len(races)), make([]int64, len(races))
+		for j, race := range races {
+			raceID, locID, err := CreateRace(race.Name, race.Lat, race.Lon, race.TimeZone, race.Start, race.End)
+			if err != nil {
+				log.Printf("Greška pri uvozu %d. utrke: %v", rows[j]+1, err)
+				report.Rows[rows[j]].Status = ImportFailed
+				report.Rows[rows[j]].Error = asError(err).Message
+				report.Failed++
+				continue
+			}
+			raceIDs[j], locIDs[j] = raceID, locID
+		}
+	}
+
+	// Za svaku lokaciju prognoze dohvaćamo samo jednom,
+	// za interval od najranijeg početka do najkasnijeg kraja utrka
+	type window struct {
+		lat, lon   string
+		start, end time.Time
+	}
+	locations := make(map[int64]*window)
+	var order []int64
+	for j, race := range races {
+		if raceIDs[j] == 0 {
+			continue
+		}
+		report.Rows[rows[j]].Status, report.Rows[rows[j]].ID = ImportCreated, raceIDs[j]
+		report.Created++
+
+		w := locations[locIDs[j]]
+		if w == nil {
+			locations[locIDs[j]] = &window{race.Lat, race.Lon, race.Start, race.End}
+			order = append(order, locIDs[j])
+			continue
+		}
+		if race.Start.Before(w.start) {
+			w.start = race.Start
+		}
+		if race.End.After(w.end) {
+			w.end = race.End
+		}
+	}
+
+	// Lokacije dohvaćamo redom, kako veliki uvoz ne bi
+	// zauzeo sve pozive prema izvoru prognoze odjednom
+	go func() {
+		for _, locID := range order {
+			w := locations[locID]
+			s.refreshForecasts(locID, w.lat, w.lon, w.start, w.end)
+		}
+	}()
+	return report, nil
+}
+
+// Delete briše utrku s određenim id-om
+func (s *RaceService) Delete(id int64) error {
+	return DeleteRace(int(id))
+}
+
+// Forecast vraća prognoze za utrku, s opisom vremena na jeziku lang
+func (s *RaceService) Forecast(id int64, from, to time.Time, lang string) (RaceForecast, error) {
+	data, err := GetWeather(int(id), from, to)
+	if err != nil {
+		return data, err
+	}
+	for i := range data.Forecasts {
+		data.Forecasts[i].Localize(lang)
+	}
+	return data, nil
+}
+
+// Summary vraća sažetak prognoza za utrku
+func (s *RaceService) Summary(id int64, from, to time.Time, lang string) (ForecastSummary, error) {
+	data, err := GetWeather(int(id), from, to)
+	if err != nil {
+		return ForecastSummary{}, err
+	}
+	start, end, err := summaryWindow(data.Race, from, to, time.Now())
+	if err != nil {
+		return ForecastSummary{}, InternalError("neispravno vrijeme utrke", err)
+	}
+	return Summarize(data, start, end, lang), nil
+}
+
+// PointForecast vraća prognoze za točku koja nije utrka, od from do to.
+// Nulto vrijeme znači da interval s te strane nije ograničen.
+func (s *RaceService) PointForecast(ctx context.Context, p Point, from, to time.Time, lang string) (PointForecast, error) {
+	key := roundPoint(p)
+	data, fetchedAt, err := s.Cache.Forecast(ctx, key)
+	if err != nil {
+		return PointForecast{}, UpstreamError("neuspjelo dohvaćanje prognoze", err)
+	}
+	return s.pointForecast(key, data, fetchedAt, from, to, lang)
+}
+
+// BatchForecast vraća prognoze za više točaka, a rezultat svake točke je
+// prognoza ili greška. Prognozu za istu zaokruženu točku dohvaća samo
+// jednom, a najviše BatchConcurrency dohvata istovremeno čeka na izvor.
+func (s *RaceService) BatchForecast(ctx context.Context, items []BatchItem, lang string) []BatchResult {
+
+	type query struct {
+		key      Location
+		from, to time.Time
+	}
+	type fetched struct {
+		data      []WeatherData
+		fetchedAt time.Time
+		err       error
+	}
+
+	results := make([]BatchResult, len(items))
+	queries := make([]query, len(items))
+	// keys su različite zaokružene točke, a index mjesto točke u keys
+	var keys []Location
+	index := make(map[Location]int)
+	for i, item := range items {
+		results[i].Index = i
+		p, from, to, errs := parsePointForecast(string(item.Lat), string(item.Lon), item.From, item.To)
+		if len(errs) > 0 {
+			results[i].Err = ValidationError("podaci točke nisu ispravni", errs...)
+			continue
+		}
+		queries[i] = query{roundPoint(p), from, to}
+		if _, ok := index[queries[i].key]; !ok {
+			index[queries[i].key] = len(keys)
+			keys = append(keys, queries[i].key)
+		}
+	}
+
+	// Svaku lokaciju dohvaćamo samo jednom, kao u automatskom ažuriranju.
+	// Svaki dohvat piše samo u svoje mjesto u nizu fetches.
+	fetches := make([]fetched, len(keys))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, BatchConcurrency)
+	for j, key := range keys {
+		wg.Add(1)
+		go func(j int, key Location) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			f := &fetches[j]
+			f.data, f.fetchedAt, f.err = s.Cache.Forecast(ctx, key)
+		}(j, key)
+	}
+	wg.Wait()
+
+	for i, q := range queries {
+		if results[i].Err != nil {
+			continue
+		}
+		f := fetches[index[q.key]]
+		if f.err != nil {
+			results[i].Err = UpstreamError("neuspjelo dohvaćanje prognoze", f.err)
+			continue
+		}
+		forecast, err := s.pointForecast(q.key, f.data, f.fetchedAt, q.from, q.to, lang)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Forecast = &forecast
+	}
+	return results
+}
+
+// pointForecast iz dohvaćenih prognoza za točku uzima one od from do to
+// i vraća ih u vremenskoj zoni točke, s opisom vremena na jeziku lang.
+func (s *RaceService) pointForecast(key Location, data []WeatherData, fetchedAt, from, to time.Time, lang string) (PointForecast, error) {
+
+	zone := locationZone(key.Lat, key.Lon)
+	loc := raceLocation(zone)
+	result := PointForecast{
+		Lat:       key.Lat,
+		Lon:       key.Lon,
+		TimeZone:  zone,
+		Provider:  s.Provider.Name(),
+		FetchedAt: fetchedAt.In(loc).Format(time.RFC3339),
+		Forecasts: []WeatherData{},
+	}
+
+	for _, d := range data {
+		t, err := time.Parse(time.RFC3339, d.Date)
+		if err != nil {
+			return result, InternalError("neispravno vrijeme prognoze", err)
+		}
+		if (!from.IsZero() && t.Before(from)) || (!to.IsZero() && t.After(to)) {
+			continue
+		}
+		// Niz iz spremnika dijele svi upiti pa mijenjamo samo kopiju
+		d.Date = t.In(loc).Format(time.RFC3339)
+		d.Localize(lang)
+		result.Forecasts = append(result.Forecasts, d)
+	}
+	return result, nil
+}
+
+// refreshForecasts dohvaća prognoze za lokaciju utrke i sprema ih u bazu
+func (s *RaceService) refreshForecasts(locID int64, lat, lon string, start, end time.Time) {
+
+	data, err := s.Provider.Forecast(context.Background(), lat, lon, start, end)
+	if err != nil {
+		log.Printf("Greška pri dohvaćanju prognoza za lokaciju %d: %v", locID, err)
+		return
+	}
+
+	err = InsertWeatherPodcast(data, locID, s.Provider.Name())
+	if err != nil {
+		log.Printf("Greška pri dodavanju prognoza za lokaciju %d: %v", locID, err)
+	}
+}
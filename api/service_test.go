@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// raceRequest vraća ispravne podatke utrke koja počinje za days dana
+func raceRequest(name string, days int) RaceRequest {
+	start := time.Now().UTC().Truncate(time.Hour).AddDate(0, 0, days)
+	return RaceRequest{
+		Name:  name,
+		Lat:   "45.81",
+		Lon:   "15.98",
+		Start: start.Format(time.RFC3339),
+		End:   start.Add(4 * time.Hour).Format(time.RFC3339),
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	s := NewRaceService(&fakeProvider{}, time.Hour)
+	invalid := raceRequest("", 1)
+	invalid.Lat = "91"
+	ragged := raceRequest("Redak", 1)
+	ragged.rowErrors = ValidationErrors{{"row", CodeFieldCount, "redak ima 3 stupaca, a zaglavlje 5"}}
+
+	report, err := s.Import([]RaceRequest{raceRequest("Maraton", 1), invalid, ragged}, ImportBestEffort, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 3 || report.Valid != 1 || report.Invalid != 2 || report.Created != 0 || !report.DryRun {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	want := []struct {
+		status string
+		errors int
+	}{{ImportValid, 0}, {ImportInvalid, 2}, {ImportInvalid, 1}}
+	for i, w := range want {
+		row := report.Rows[i]
+		if row.Row != i+1 || row.Status != w.status || len(row.Errors) != w.errors {
+			t.Errorf("row %d: got %+v, want status %s with %d errors", i+1, row, w.status, w.errors)
+		}
+	}
+
+	// Neispravan redak ne provjeravamo dalje, pa ima samo grešku retka
+	if report.Rows[2].Errors[0].Code != CodeFieldCount {
+		t.Errorf("got %v", report.Rows[2].Errors)
+	}
+}
+
+func TestImportAtomicSkipsValidRows(t *testing.T) {
+	s := NewRaceService(&fakeProvider{}, time.Hour)
+	invalid := raceRequest("Prošla", -1)
+
+	// Uz neispravnu utrku atomic uvoz ne dodaje nijednu, pa ne treba bazu
+	report, err := s.Import([]RaceRequest{raceRequest("Maraton", 1), invalid}, ImportAtomic, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Rows[0].Status != ImportSkipped || report.Rows[1].Status != ImportInvalid || report.Created != 0 {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
+
+func TestBatchForecast(t *testing.T) {
+	p := &fakeProvider{}
+	s := NewRaceService(p, time.Hour)
+	from := time.Now().UTC().Truncate(time.Hour).Add(2 * time.Hour)
+	to := from.Add(2 * time.Hour)
+
+	items := []BatchItem{
+		{Lat: "45.816", Lon: "15.9819", From: from.Format(time.RFC3339), To: to.Format(time.RFC3339)},
+		{Lat: "45.8249", Lon: "15.9821"},
+		{Lat: "91", Lon: "15.98"},
+		{Lat: "43.508", Lon: "16.440", From: to.Format(time.RFC3339), To: from.Format(time.RFC3339)},
+		{Lat: "43.508", Lon: "16.440", To: to.Format(time.RFC3339)},
+	}
+	results := s.BatchForecast(context.Background(), items, "en")
+	if len(results) != len(items) {
+		t.Fatalf("got %d results, want %d", len(results), len(items))
+	}
+
+	// Prve dvije i zadnje dvije točke su iste zaokružene točke, pa su potrebna samo dva dohvata
+	if p.Calls() != 2 {
+		t.Errorf("got %d calls, want 2", p.Calls())
+	}
+	for i, r := range results {
+		if r.Index != i {
+			t.Errorf("result %d has index %d", i, r.Index)
+		}
+	}
+
+	f := results[0].Forecast
+	if results[0].Err != nil || f == nil {
+		t.Fatalf("first result: %v", results[0].Err)
+	}
+	if f.Lat != "45.82" || f.Lon != "15.98" || f.Provider != "fake" || len(f.Forecasts) != 3 {
+		t.Errorf("unexpected first forecast %+v", f)
+	}
+	if f.Forecasts[0].WeatherIcon != "clear sky" {
+		t.Errorf("description = %q, want English", f.Forecasts[0].WeatherIcon)
+	}
+	if !mustParseTime(t, f.Forecasts[0].Date).Equal(from) {
+		t.Errorf("first forecast at %s, want %s", f.Forecasts[0].Date, from)
+	}
+	if results[1].Forecast == nil || len(results[1].Forecast.Forecasts) <= 3 {
+		t.Errorf("second result without an interval must have all forecasts")
+	}
+
+	for _, i := range []int{2, 3} {
+		if results[i].Forecast != nil || asError(results[i].Err).Kind != KindValidation {
+			t.Errorf("result %d: got %v, want a validation error", i, results[i].Err)
+		}
+	}
+	if results[4].Forecast == nil {
+		t.Errorf("result 4: %v", results[4].Err)
+	}
+}
+
+func TestBatchForecastProviderError(t *testing.T) {
+	s := NewRaceService(&fakeProvider{err: errors.New("nedostupan")}, time.Hour)
+	results := s.BatchForecast(context.Background(), []BatchItem{{Lat: "45.81", Lon: "15.98"}}, "hr")
+	if results[0].Forecast != nil || asError(results[0].Err).Kind != KindUpstream {
+		t.Errorf("got %v, want an upstream error", results[0].Err)
+	}
+}
+
+func TestBatchForecastConcurrency(t *testing.T) {
+	p := &fakeProvider{release: make(chan struct{})}
+	s := NewRaceService(p, time.Hour)
+
+	var items []BatchItem
+	for _, lat := range []Coordinate{"41", "42", "43", "44", "45", "46", "47"} {
+		items = append(items, BatchItem{Lat: lat, Lon: "16"})
+	}
+	done := make(chan []BatchResult)
+	go func() { done <- s.BatchForecast(context.Background(), items, "hr") }()
+
+	// Dok izvor ne odgovori, čeka najviše BatchConcurrency dohvata
+	time.Sleep(50 * time.Millisecond)
+	if p.Calls() != BatchConcurrency {
+		t.Errorf("got %d concurrent calls, want %d", p.Calls(), BatchConcurrency)
+	}
+	close(p.release)
+
+	for i, r := range <-done {
+		if r.Forecast == nil {
+			t.Errorf("result %d: %v", i, r.Err)
+		}
+	}
+	if p.Calls() != len(items) {
+		t.Errorf("got %d calls, want %d", p.Calls(), len(items))
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"math"
+	"time"
+)
+
+// Summarize računa sažetak prognoza za interval od start do end. Oborine
+// prognoze brojimo samo za dio njenog perioda koji je unutar intervala.
+// Najgori sat je prognoza s najgorom kategorijom vremena, a kod jednake
+// kategorije ona s više oborina pa ona s jačim udarima vjetra.
+func Summarize(data RaceForecast, start, end time.Time, lang string) ForecastSummary {
+
+	summary := ForecastSummary{
+		Race:      data.Race,
+		Provider:  data.Provider,
+		FetchedAt: data.FetchedAt,
+		Points:    len(data.Forecasts),
+	}
+	if len(data.Forecasts) == 0 {
+		return summary
+	}
+
+	summary.From = data.Forecasts[0].Date
+	summary.To = data.Forecasts[len(data.Forecasts)-1].Date
+	summary.TempMin = math.Inf(1)
+	summary.TempMax = math.Inf(-1)
+
+	var temps []float64
+	worst := data.Forecasts[0]
+	for _, d := range data.Forecasts {
+		temps = append(temps, d.Temp)
+		summary.TempMin = math.Min(summary.TempMin, d.Temp)
+		summary.TempMax = math.Max(summary.TempMax, d.Temp)
+		overlap := periodOverlap(d, start, end)
+		summary.RainTotal += d.Rain * overlap
+		summary.SnowTotal += d.Snow * overlap
+		summary.WindSpeedMax = math.Max(summary.WindSpeedMax, d.WindSpeed)
+		summary.WindGustMax = math.Max(summary.WindGustMax, d.WindGust)
+		if worse(d, worst) {
+			worst = d
+		}
+	}
+
+	summary.TempMean = round2(mean(temps))
+	summary.RainTotal = round2(summary.RainTotal)
+	summary.SnowTotal = round2(summary.SnowTotal)
+
+	worst.Localize(lang)
+	summary.WorstCondition = worst.Condition
+	summary.WorstDescription = worst.WeatherIcon
+	summary.WorstHour = worst.Date
+	return summary
+}
+
+// periodOverlap vraća udio perioda prognoze koji je unutar intervala od start
+// do end. Prognoza vrijedi za period duljine razmaka prognoza sa svojim
+// vremenom u sredini, pa dnevna prognoza u podne vrijedi za cijeli dan.
+func periodOverlap(d WeatherData, start, end time.Time) float64 {
+	t, err := time.Parse(time.RFC3339, d.Date)
+	if err != nil {
+		return 1
+	}
+	period := time.Duration(resolutionHours(d.Resolution) * float64(time.Hour))
+	from, to := t.Add(-period/2), t.Add(period/2)
+	if from.Before(start) {
+		from = start
+	}
+	if to.After(end) {
+		to = end
+	}
+	if !to.After(from) {
+		return 0
+	}
+	return float64(to.Sub(from)) / float64(period)
+}
+
+// summaryWindow vraća interval sažetka za utrku. Kao i kod dohvata prognoza,
+// to je interval utrke sužen s from i to, a ne počinje prije trenutka now.
+func summaryWindow(race Race, from, to, now time.Time) (start, end time.Time, err error) {
+	if start, err = time.Parse(time.RFC3339, race.Begin); err != nil {
+		return start, end, err
+	}
+	if end, err = time.Parse(time.RFC3339, race.End); err != nil {
+		return start, end, err
+	}
+	for _, t := range []time.Time{from, now} {
+		if t.After(start) {
+			start = t
+		}
+	}
+	if !to.IsZero() && to.Before(end) {
+		end = to
+	}
+	return start, end, nil
+}
+
+// worse provjerava da li su uvjeti u prognozi a gori nego u prognozi b
+func worse(a, b WeatherData) bool {
+	sa, sb := conditionSeverity[a.Condition], conditionSeverity[b.Condition]
+	if sa != sb {
+		return sa > sb
+	}
+	pa, pb := a.Rain+a.Snow, b.Rain+b.Snow
+	if pa != pb {
+		return pa > pb
+	}
+	return a.WindGust > b.WindGust
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+// weatherAt vraća satnu prognozu za zadano vrijeme s WMO kodom vremena
+func weatherAt(date string, temp, rain, gust float64, code int) WeatherData {
+	d := WeatherData{Date: date, Temp: temp, Rain: rain, WindSpeed: gust / 2, WindGust: gust, Resolution: Resolution1h}
+	d.setCondition(code)
+	return d
+}
+
+func TestSummarize(t *testing.T) {
+	data := RaceForecast{
+		Race:     Race{ID: 7, Name: "Maraton"},
+		Provider: "openmeteo",
+		Forecasts: []WeatherData{
+			weatherAt("2030-05-04T08:00:00Z", 10, 0, 4, 0),
+			weatherAt("2030-05-04T09:00:00Z", 12, 1.2, 6, 61),
+			weatherAt("2030-05-04T10:00:00Z", 15, 0.4, 12, 61),
+			weatherAt("2030-05-04T11:00:00Z", 11, 0.3, 8, 3),
+		},
+	}
+	// Interval pokriva cijele periode svih satnih prognoza
+	start := time.Date(2030, 5, 4, 7, 30, 0, 0, time.UTC)
+	s := Summarize(data, start, start.Add(4*time.Hour), "en")
+
+	if s.Race.ID != 7 || s.Provider != "openmeteo" || s.Points != 4 {
+		t.Errorf("unexpected metadata %+v", s)
+	}
+	if s.From != "2030-05-04T08:00:00Z" || s.To != "2030-05-04T11:00:00Z" {
+		t.Errorf("got interval %s - %s", s.From, s.To)
+	}
+	if s.TempMin != 10 || s.TempMax != 15 || s.TempMean != 12 {
+		t.Errorf("unexpected temperatures %+v", s)
+	}
+	if s.RainTotal != 1.9 || s.SnowTotal != 0 || s.WindSpeedMax != 6 || s.WindGustMax != 12 {
+		t.Errorf("unexpected totals %+v", s)
+	}
+
+	// Od dvije prognoze s kišom najgora je ona s više oborina
+	if s.WorstCondition != ConditionRain || s.WorstHour != "2030-05-04T09:00:00Z" || s.WorstDescription != "light rain" {
+		t.Errorf("unexpected worst hour %+v", s)
+	}
+}
+
+func TestSummarizeMixedResolutions(t *testing.T) {
+	hourly := weatherAt("2030-05-04T09:00:00Z", 12, 1.2, 6, 61)
+	threeHourly := weatherAt("2030-05-04T12:00:00Z", 14, 3, 8, 61)
+	threeHourly.Resolution = Resolution3h
+	sixHourly := weatherAt("2030-05-04T15:00:00Z", 13, 6, 8, 63)
+	sixHourly.Resolution = Resolution6h
+	daily := weatherAt("2030-05-04T12:00:00Z", 11, 0, 10, 73)
+	daily.Snow, daily.Resolution = 24, Resolution1d
+
+	data := RaceForecast{Forecasts: []WeatherData{hourly, threeHourly, daily, sixHourly}}
+	start := time.Date(2030, 5, 4, 8, 0, 0, 0, time.UTC)
+	s := Summarize(data, start, start.Add(6*time.Hour), "hr")
+
+	// Tročasovna prognoza je cijela u utrci, od šestsatne su u utrci
+	// 2 sata, a od dnevne 6 sati
+	if s.RainTotal != 6.2 || s.SnowTotal != 6 {
+		t.Errorf("rain = %v, snow = %v, want 6.2 and 6", s.RainTotal, s.SnowTotal)
+	}
+}
+
+func TestPeriodOverlap(t *testing.T) {
+	start := time.Date(2030, 5, 4, 8, 0, 0, 0, time.UTC)
+	end := start.Add(3 * time.Hour)
+	tests := []struct {
+		date       string
+		resolution string
+		want       float64
+	}{
+		{"2030-05-04T09:00:00Z", Resolution1h, 1},
+		{"2030-05-04T08:00:00Z", Resolution1h, 0.5},
+		{"2030-05-04T11:00:00Z", Resolution3h, 0.5},
+		{"2030-05-04T14:00:00Z", Resolution3h, 0},
+		{"2030-05-04T12:00:00Z", Resolution1d, 0.125},
+		{"2030-05-04T10:00:00+02:00", "", 0.5},
+	}
+	for _, tt := range tests {
+		d := WeatherData{Date: tt.date, Resolution: tt.resolution}
+		if got := periodOverlap(d, start, end); got != tt.want {
+			t.Errorf("periodOverlap(%s, %s) = %v, want %v", tt.date, tt.resolution, got, tt.want)
+		}
+	}
+}
+
+func TestSummaryWindow(t *testing.T) {
+	race := Race{Begin: "2030-05-04T10:00:00+02:00", End: "2030-05-04T16:00:00+02:00"}
+	now := time.Date(2030, 5, 4, 9, 0, 0, 0, time.UTC)
+	to := time.Date(2030, 5, 4, 12, 0, 0, 0, time.UTC)
+
+	// Utrka je već počela, a kraj je sužen s to
+	start, end, err := summaryWindow(race, time.Time{}, to, now)
+	if err != nil || !start.Equal(now) || !end.Equal(to) {
+		t.Errorf("got %v - %v, %v", start, end, err)
+	}
+
+	start, end, err = summaryWindow(race, time.Time{}, time.Time{}, now.Add(-24*time.Hour))
+	if err != nil || !start.Equal(time.Date(2030, 5, 4, 8, 0, 0, 0, time.UTC)) || !end.Equal(start.Add(6*time.Hour)) {
+		t.Errorf("got %v - %v, %v", start, end, err)
+	}
+}
+
+func TestSummarizeEmpty(t *testing.T) {
+	s := Summarize(RaceForecast{Race: Race{ID: 7}}, time.Time{}, time.Time{}, "hr")
+	if s.Points != 0 || s.TempMin != 0 || s.TempMax != 0 || s.WorstHour != "" {
+		t.Errorf("unexpected summary %+v", s)
+	}
+}
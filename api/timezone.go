@@ -0,0 +1,63 @@
+package api
+
+import (
+	"log"
+	"strconv"
+
+	// Granice vremenskih zona ugrađene u program,
+	// pa zonu za koordinate doznajemo bez vanjskog servisa
+	"github.com/bradfitz/latlong"
+)
+
+// locationZone vraća naziv IANA vremenske zone za koordinate, npr. Europe/Zagreb.
+// Za koordinate izvan svih zona, npr. na otvorenom moru, vraća prazan string.
+func locationZone(lat, lon string) string {
+	la, err := strconv.ParseFloat(lat, 64)
+	if err != nil {
+		return ""
+	}
+	lo, err := strconv.ParseFloat(lon, 64)
+	if err != nil {
+		return ""
+	}
+	return latlong.LookupZoneName(la, lo)
+}
+
+// FillLocationZones postavlja vremensku zonu lokacijama koje je još nemaju,
+// npr. lokacijama dodanim prije nego što smo zone pamtili.
+func FillLocationZones() error {
+
+	rows, err := db.Query(`SELECT location_id, lat, lon FROM locations WHERE zone = ''`)
+	if err != nil {
+		return err
+	}
+	type location struct {
+		id       int
+		lat, lon string
+	}
+	var locations []location
+	for rows.Next() {
+		var l location
+		if err := rows.Scan(&l.id, &l.lat, &l.lon); err != nil {
+			rows.Close()
+			return err
+		}
+		locations = append(locations, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, l := range locations {
+		zone := locationZone(l.lat, l.lon)
+		if zone == "" {
+			continue
+		}
+		if _, err := db.Exec(`UPDATE locations SET zone = $1 WHERE location_id = $2`, zone, l.id); err != nil {
+			return err
+		}
+		log.Printf("Lokaciji %d postavljena vremenska zona %s", l.id, zone)
+	}
+	return nil
+}
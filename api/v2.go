@@ -0,0 +1,515 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handleri API-ja v2. Koriste isti RaceService kao i v1, a razlikuju se
+// samo u obliku odgovora: engleski nazivi polja u snake_case obliku,
+// koordinate kao brojevi i omotnica s poljima data, meta i links.
+
+// V2Prefix je putanja pod kojom se nalazi API v2
+const V2Prefix = "/api/v2"
+
+// ListRacesV2Handler vraća handler koji dohvaća jednu stranicu utrka.
+// Ukupan broj utrka i stranica su u meta, a susjedne stranice u links.
+func ListRacesV2Handler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q, err := bindRaceQuery(c, DefaultPageSize)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		races, total, err := s.List(q)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		data := make([]RaceV2, len(races))
+		for i, r := range races {
+			data[i] = raceV2(r)
+		}
+		c.JSON(http.StatusOK, Envelope{
+			Data: data,
+			Meta: map[string]interface{}{
+				"count":  len(data),
+				"total":  total,
+				"limit":  q.Limit,
+				"offset": q.Offset,
+			},
+			Links: pageLinks(c, q, total),
+		})
+	}
+}
+
+// pageLinks vraća adrese trenutne, sljedeće i prethodne stranice,
+// s istim filterima i poretkom kao u zahtjevu
+func pageLinks(c *gin.Context, q RaceQuery, total int) map[string]string {
+	page := func(offset int) string {
+		u := *c.Request.URL
+		values := u.Query()
+		values.Set("limit", strconv.Itoa(q.Limit))
+		values.Set("offset", strconv.Itoa(offset))
+		u.RawQuery = values.Encode()
+		return u.RequestURI()
+	}
+
+	links := map[string]string{"self": c.Request.URL.RequestURI()}
+	if q.Offset+q.Limit < total {
+		links["next"] = page(q.Offset + q.Limit)
+	}
+	if q.Offset > 0 {
+		prev := q.Offset - q.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links["prev"] = page(prev)
+	}
+	return links
+}
+
+// NearbyRacesV2Handler vraća handler koji dohvaća utrke u blizini točke
+func NearbyRacesV2Handler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q, err := bindNearbyQuery(c, DefaultPageSize)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		respondNearbyV2(c, s, q)
+	}
+}
+
+// RacesWithinV2Handler vraća handler koji dohvaća utrke unutar područja
+func RacesWithinV2Handler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q, err := bindWithinQuery(c, DefaultPageSize)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		respondNearbyV2(c, s, q)
+	}
+}
+
+// respondNearbyV2 odgovara utrkama poredanim po udaljenosti od točke
+// pretrage, a točku i polumjer vraća u meta
+func respondNearbyV2(c *gin.Context, s *RaceService, q NearbyQuery) {
+	races, err := s.Nearby(q)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	data := make([]NearbyRaceV2, len(races))
+	for i, r := range races {
+		data[i] = NearbyRaceV2{RaceV2: raceV2(r.Race), DistanceKm: r.DistanceKm}
+	}
+	meta := map[string]interface{}{
+		"count": len(data),
+		"limit": q.Limit,
+		"lat":   q.Point.Lat,
+		"lon":   q.Point.Lon,
+	}
+	if q.Area == nil {
+		meta["radius_km"] = q.RadiusKm
+	}
+	c.JSON(http.StatusOK, Envelope{
+		Data:  data,
+		Meta:  meta,
+		Links: map[string]string{"self": c.Request.URL.RequestURI()},
+	})
+}
+
+// ImportRacesV2Handler vraća handler za uvoz utrka. Rezultat za svaku
+// utrku je u data, a broj ispravnih, dodanih i neuspjelih utrka u meta.
+func ImportRacesV2Handler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reqs, mode, dryRun, err := bindImport(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		report, err := s.Import(reqs, mode, dryRun)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(importStatus(report), Envelope{
+			Data: report.Rows,
+			Meta: map[string]interface{}{
+				"mode":    report.Mode,
+				"dry_run": report.DryRun,
+				"total":   report.Total,
+				"valid":   report.Valid,
+				"invalid": report.Invalid,
+				"created": report.Created,
+				"failed":  report.Failed,
+			},
+		})
+	}
+}
+
+// GetRaceV2Handler vraća handler koji dohvaća jednu utrku
+func GetRaceV2Handler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := paramID(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		race, err := s.Get(id)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, Envelope{Data: raceV2(race), Links: raceLinks(race.ID)})
+	}
+}
+
+// CreateRaceV2Handler vraća handler za dodavanje nove utrke.
+// Odgovara sa 201 Created i adresom nove utrke u zaglavlju Location.
+func CreateRaceV2Handler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := bindRaceRequest(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		id, err := s.Create(req)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		race, err := s.Get(id)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		links := raceLinks(race.ID)
+		c.Header("Location", links["self"])
+		c.JSON(http.StatusCreated, Envelope{Data: raceV2(race), Links: links})
+	}
+}
+
+// UpdateRaceV2Handler vraća handler za ažuriranje utrke.
+// U meta.changed javlja da li se utrka promijenila.
+func UpdateRaceV2Handler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := paramID(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		req, err := bindRaceRequest(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		changed, err := s.Update(id, req)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		race, err := s.Get(id)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, Envelope{
+			Data:  raceV2(race),
+			Meta:  map[string]interface{}{"changed": changed},
+			Links: raceLinks(race.ID),
+		})
+	}
+}
+
+// PatchRaceV2Handler vraća handler za djelomično ažuriranje utrke
+// JSON Merge Patch dokumentom. U meta.changed javlja da li se utrka promijenila.
+func PatchRaceV2Handler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := paramID(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		patch, err := bindRacePatch(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		changed, err := s.Patch(id, patch)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		race, err := s.Get(id)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, Envelope{
+			Data:  raceV2(race),
+			Meta:  map[string]interface{}{"changed": changed},
+			Links: raceLinks(race.ID),
+		})
+	}
+}
+
+// DeleteRaceV2Handler vraća handler koji briše utrku i odgovara sa 204 No Content
+func DeleteRaceV2Handler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := paramID(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if err := s.Delete(id); err != nil {
+			respondError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GetForecastV2Handler vraća handler koji dohvaća prognoze za utrku
+func GetForecastV2Handler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, from, to, err := forecastParams(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		data, err := s.Forecast(id, from, to, c.DefaultQuery("lang", DefaultDescriptionLang))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		forecasts := make([]ForecastV2, len(data.Forecasts))
+		for i, d := range data.Forecasts {
+			forecasts[i] = forecastV2(d)
+		}
+		links := raceLinks(data.Race.ID)
+		links["race"], links["self"] = links["self"], c.Request.URL.RequestURI()
+		c.JSON(http.StatusOK, Envelope{
+			Data:  forecasts,
+			Meta:  forecastMeta(data, len(forecasts)),
+			Links: links,
+		})
+	}
+}
+
+// GetSummaryV2Handler vraća handler koji daje sažetak prognoza za utrku
+func GetSummaryV2Handler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, from, to, err := forecastParams(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		summary, err := s.Summary(id, from, to, c.DefaultQuery("lang", DefaultDescriptionLang))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		links := raceLinks(summary.Race.ID)
+		links["race"], links["self"] = links["self"], c.Request.URL.RequestURI()
+		c.JSON(http.StatusOK, Envelope{
+			Data: summaryV2(summary),
+			Meta: forecastMeta(RaceForecast{
+				Race:      summary.Race,
+				Provider:  summary.Provider,
+				FetchedAt: summary.FetchedAt,
+			}, summary.Points),
+			Links: links,
+		})
+	}
+}
+
+// GetPointForecastV2Handler vraća handler koji dohvaća prognoze za proizvoljnu točku
+func GetPointForecastV2Handler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, from, to, err := bindPointForecast(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		data, err := s.PointForecast(c.Request.Context(), p, from, to, c.DefaultQuery("lang", DefaultDescriptionLang))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		f := pointForecastV2(data)
+		c.JSON(http.StatusOK, Envelope{
+			Data: f.Forecasts,
+			Meta: map[string]interface{}{
+				"lat":        f.Lat,
+				"lon":        f.Lon,
+				"time_zone":  f.TimeZone,
+				"provider":   f.Provider,
+				"fetched_at": f.FetchedAt,
+				"count":      len(f.Forecasts),
+			},
+			Links: map[string]string{"self": c.Request.URL.RequestURI()},
+		})
+	}
+}
+
+// BatchForecastV2Handler vraća handler koji dohvaća prognoze za više točaka.
+// Broj točaka s greškom je u meta.errors.
+func BatchForecastV2Handler(s *RaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		items, err := bindBatchRequest(c)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		results := s.BatchForecast(c.Request.Context(), items, c.DefaultQuery("lang", DefaultDescriptionLang))
+		data := make([]BatchResultV2, len(results))
+		failed := 0
+		for i, r := range results {
+			data[i].Index = r.Index
+			if r.Err != nil {
+				data[i].Error = problemFor(c, r.Err)
+				failed++
+				continue
+			}
+			f := pointForecastV2(*r.Forecast)
+			data[i].Data = &f
+		}
+		c.JSON(http.StatusOK, Envelope{
+			Data: data,
+			Meta: map[string]interface{}{"count": len(data), "errors": failed},
+		})
+	}
+}
+
+// forecastMeta vraća meta podatke odgovora s prognozama
+func forecastMeta(data RaceForecast, count int) map[string]interface{} {
+	meta := map[string]interface{}{
+		"race":     raceV2(data.Race),
+		"provider": data.Provider,
+		"count":    count,
+	}
+	if data.FetchedAt != "" {
+		meta["fetched_at"] = data.FetchedAt
+	}
+	return meta
+}
+
+// raceLinks vraća adrese utrke i njenih prognoza
+func raceLinks(id int) map[string]string {
+	self := fmt.Sprintf("%s/races/%d", V2Prefix, id)
+	return map[string]string{
+		"self":     self,
+		"forecast": self + "/forecast",
+		"summary":  self + "/forecast/summary",
+	}
+}
+
+// raceV2 pretvara utrku u oblik za API v2
+func raceV2(r Race) RaceV2 {
+	// Koordinate su u bazi brojevi pa su uvijek ispravne
+	lat, _ := strconv.ParseFloat(r.Lat, 64)
+	lon, _ := strconv.ParseFloat(r.Lon, 64)
+	return RaceV2{
+		ID:       r.ID,
+		Name:     r.Name,
+		Lat:      lat,
+		Lon:      lon,
+		Start:    r.Begin,
+		End:      r.End,
+		TimeZone: r.TimeZone,
+	}
+}
+
+// forecastV2 pretvara prognozu u oblik za API v2
+func forecastV2(d WeatherData) ForecastV2 {
+	f := ForecastV2{
+		Time:                     d.Date,
+		Temperature:              d.Temp,
+		FeelsLike:                d.FeelsLike,
+		Humidity:                 d.Humidity,
+		Pressure:                 d.Pressure,
+		Clouds:                   d.Clouds,
+		Visibility:               d.Visibility,
+		WindSpeed:                d.WindSpeed,
+		WindDeg:                  d.WindDeg,
+		WindGust:                 d.WindGust,
+		Rain:                     d.Rain,
+		Snow:                     d.Snow,
+		PrecipitationProbability: d.Pop,
+		Description:              d.WeatherIcon,
+		Condition:                d.Condition,
+		WMOCode:                  d.WMOCode,
+		ConditionID:              d.ConditionID,
+		IconCode:                 d.IconCode,
+		Resolution:               d.Resolution,
+	}
+	if e := d.Ensemble; e != nil {
+		f.Ensemble = &EnsembleV2{
+			Sources:       e.Sources,
+			Temperature:   e.Temp,
+			WindSpeed:     e.WindSpeed,
+			Precipitation: e.Precipitation,
+			Agreement:     e.Agreement,
+		}
+	}
+	return f
+}
+
+// pointForecastV2 pretvara prognoze za točku u oblik za API v2
+func pointForecastV2(p PointForecast) PointForecastV2 {
+	// Koordinate su zaokružene iz ispravnih brojeva
+	lat, _ := strconv.ParseFloat(p.Lat, 64)
+	lon, _ := strconv.ParseFloat(p.Lon, 64)
+	forecasts := make([]ForecastV2, len(p.Forecasts))
+	for i, d := range p.Forecasts {
+		forecasts[i] = forecastV2(d)
+	}
+	return PointForecastV2{
+		Lat:       lat,
+		Lon:       lon,
+		TimeZone:  p.TimeZone,
+		Provider:  p.Provider,
+		FetchedAt: p.FetchedAt,
+		Forecasts: forecasts,
+	}
+}
+
+// summaryV2 pretvara sažetak prognoza u oblik za API v2
+func summaryV2(s ForecastSummary) SummaryV2 {
+	return SummaryV2{
+		From:             s.From,
+		To:               s.To,
+		Points:           s.Points,
+		TemperatureMin:   s.TempMin,
+		TemperatureMax:   s.TempMax,
+		TemperatureMean:  s.TempMean,
+		RainTotal:        s.RainTotal,
+		SnowTotal:        s.SnowTotal,
+		WindSpeedMax:     s.WindSpeedMax,
+		WindGustMax:      s.WindGustMax,
+		WorstCondition:   s.WorstCondition,
+		WorstDescription: s.WorstDescription,
+		WorstTime:        s.WorstHour,
+	}
+}
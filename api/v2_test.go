@@ -0,0 +1,68 @@
+package api
+
+import "testing"
+
+func TestRaceV2(t *testing.T) {
+	race := Race{ID: 3, Name: "Maraton", Lat: "45.81", Lon: "15.98",
+		Begin: "2030-05-04T08:00:00+02:00", End: "2030-05-04T14:00:00+02:00", TimeZone: "Europe/Zagreb"}
+
+	want := RaceV2{ID: 3, Name: "Maraton", Lat: 45.81, Lon: 15.98,
+		Start: race.Begin, End: race.End, TimeZone: "Europe/Zagreb"}
+	if got := raceV2(race); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	links := raceLinks(3)
+	if links["self"] != "/api/v2/races/3" || links["summary"] != "/api/v2/races/3/forecast/summary" {
+		t.Errorf("got links %v", links)
+	}
+}
+
+func TestForecastV2(t *testing.T) {
+	d := WeatherData{Date: "2030-05-04T08:00:00Z", Temp: 14, Rain: 0.5, Resolution: Resolution1h}
+	d.setCondition(61)
+
+	f := forecastV2(d)
+	if f.Time != d.Date || f.Temperature != 14 || f.Rain != 0.5 || f.Description != "slaba kiša" ||
+		f.Condition != ConditionRain || f.WMOCode != 61 || f.Resolution != Resolution1h {
+		t.Errorf("got %+v", f)
+	}
+	if f.Ensemble != nil {
+		t.Error("ensemble should be omitted for a single source")
+	}
+
+	d.Ensemble = &EnsembleStats{Sources: []string{"openmeteo", "metno"}, Agreement: 0.8}
+	if f := forecastV2(d); f.Ensemble == nil || len(f.Ensemble.Sources) != 2 || f.Ensemble.Agreement != 0.8 {
+		t.Errorf("got ensemble %+v", f.Ensemble)
+	}
+}
+
+func TestRaceServiceValidates(t *testing.T) {
+	s := NewRaceService(&fakeProvider{}, 0)
+
+	// Neispravni podaci ne smiju doći do baze
+	_, err := s.Create(RaceRequest{Name: "Maraton", Lat: "91", Lon: "15.98"})
+	e, ok := err.(*Error)
+	if !ok || e.Kind != KindValidation || len(e.Fields) == 0 {
+		t.Errorf("Create: got %v", err)
+	}
+	if _, err := s.Update(1, RaceRequest{}); asError(err).Kind != KindValidation {
+		t.Errorf("Update: got %v", err)
+	}
+}
+
+func TestPageLinks(t *testing.T) {
+	c, _ := newTestContext("/api/v2/races?status=upcoming&limit=10&offset=5")
+	links := pageLinks(c, RaceQuery{Limit: 10, Offset: 5}, 30)
+	if links["next"] != "/api/v2/races?limit=10&offset=15&status=upcoming" {
+		t.Errorf("next: got %q", links["next"])
+	}
+	if links["prev"] != "/api/v2/races?limit=10&offset=0&status=upcoming" {
+		t.Errorf("prev: got %q", links["prev"])
+	}
+
+	// Zadnja stranica nema sljedeću
+	if links := pageLinks(c, RaceQuery{Limit: 10, Offset: 25}, 30); links["next"] != "" {
+		t.Errorf("last page: got next %q", links["next"])
+	}
+}
@@ -0,0 +1,270 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Kodovi grešaka u podacima zahtjeva
+const (
+	CodeRequired        = "required"
+	CodeTooLong         = "too_long"
+	CodeNotANumber      = "not_a_number"
+	CodeOutOfRange      = "out_of_range"
+	CodeInvalidDateTime = "invalid_datetime"
+	CodeInvalidTimeZone = "invalid_timezone"
+	CodeInPast          = "in_past"
+	CodeEndBeforeStart  = "end_before_start"
+	CodeNotAllowed      = "not_allowed"
+	CodeInvalidBBox     = "invalid_bbox"
+	CodeInvalidPolygon  = "invalid_polygon"
+	CodeInvalidType     = "invalid_type"
+	CodeFieldCount      = "field_count"
+)
+
+// MaxRaceNameLength je najveća duljina naziva utrke, kao stupac races.name
+const MaxRaceNameLength = 60
+
+// FieldError je greška u jednom polju zahtjeva
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors su sve greške pronađene u podacima zahtjeva
+type ValidationErrors []FieldError
+
+func (ve ValidationErrors) Error() string {
+	messages := make([]string, len(ve))
+	for i, fe := range ve {
+		messages[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Rule je pravilo za vrijednost jednog polja.
+// Vraća grešku ili nil ako je vrijednost ispravna.
+type Rule func(field, value string) *FieldError
+
+// Field je polje zahtjeva s pravilima koja mora zadovoljiti
+type Field struct {
+	Name  string
+	Value string
+	Rules []Rule
+}
+
+// Validate provjerava sva polja i vraća sve pronađene greške.
+// Za svako polje vraća samo prvu grešku, jer su kasnija pravila
+// bez smisla ako npr. vrijednost nije ni zadana.
+func Validate(fields ...Field) (errs ValidationErrors) {
+	for _, f := range fields {
+		for _, rule := range f.Rules {
+			if fe := rule(f.Name, f.Value); fe != nil {
+				errs = append(errs, *fe)
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// Required zahtijeva da polje nije prazno
+func Required() Rule {
+	return func(field, value string) *FieldError {
+		if strings.TrimSpace(value) == "" {
+			return &FieldError{field, CodeRequired, "polje je obavezno"}
+		}
+		return nil
+	}
+}
+
+// MaxLength ograničava broj znakova u polju
+func MaxLength(n int) Rule {
+	return func(field, value string) *FieldError {
+		if utf8.RuneCountInString(value) > n {
+			return &FieldError{field, CodeTooLong, fmt.Sprintf("polje može imati najviše %d znakova", n)}
+		}
+		return nil
+	}
+}
+
+// parseDecimal čita broj zapisan samo znamenkama, s predznakom i
+// decimalnom točkom. Za razliku od strconv.ParseFloat ne prihvaća
+// NaN, Inf, eksponente ni heksadecimalni zapis.
+func parseDecimal(value string) (float64, error) {
+	digits, dots := 0, 0
+	for i, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '.':
+			dots++
+		case (r == '+' || r == '-') && i == 0:
+		default:
+			return 0, fmt.Errorf("%q nije decimalni broj", value)
+		}
+	}
+	if digits == 0 || dots > 1 {
+		return 0, fmt.Errorf("%q nije decimalni broj", value)
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// FloatRange zahtijeva da je polje broj između min i max
+func FloatRange(min, max float64) Rule {
+	return func(field, value string) *FieldError {
+		f, err := parseDecimal(value)
+		if err != nil {
+			return &FieldError{field, CodeNotANumber, "polje mora biti broj"}
+		}
+		if f < min || f > max {
+			return &FieldError{field, CodeOutOfRange, fmt.Sprintf("polje mora biti između %v i %v", min, max)}
+		}
+		return nil
+	}
+}
+
+// IntRange zahtijeva da je polje cijeli broj između min i max
+func IntRange(min, max int) Rule {
+	return func(field, value string) *FieldError {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return &FieldError{field, CodeNotANumber, "polje mora biti cijeli broj"}
+		}
+		if n < min || n > max {
+			return &FieldError{field, CodeOutOfRange, fmt.Sprintf("polje mora biti između %d i %d", min, max)}
+		}
+		return nil
+	}
+}
+
+// OneOf zahtijeva da je polje jedna od zadanih vrijednosti
+func OneOf(values ...string) Rule {
+	return func(field, value string) *FieldError {
+		for _, v := range values {
+			if value == v {
+				return nil
+			}
+		}
+		return &FieldError{field, CodeNotAllowed, "polje mora biti jedno od: " + strings.Join(values, ", ")}
+	}
+}
+
+// Optional vraća pravila koja se primjenjuju samo ako polje nije prazno
+func Optional(rules ...Rule) []Rule {
+	wrapped := make([]Rule, len(rules))
+	for i, rule := range rules {
+		rule := rule
+		wrapped[i] = func(field, value string) *FieldError {
+			if value == "" {
+				return nil
+			}
+			return rule(field, value)
+		}
+	}
+	return wrapped
+}
+
+// DateTime zahtijeva da je polje ispravno vrijeme. Vrijeme bez
+// vremenske zone prihvaća samo ako je zadana zona loc.
+func DateTime(loc *time.Location) Rule {
+	return func(field, value string) *FieldError {
+		if _, err := parseRaceTime(value, loc); err != nil {
+			return &FieldError{field, CodeInvalidDateTime, err.Error()}
+		}
+		return nil
+	}
+}
+
+// TimeZone zahtijeva da je polje naziv vremenske zone iz IANA baze, npr. Europe/Zagreb
+func TimeZone() Rule {
+	return func(field, value string) *FieldError {
+		if _, err := loadTimeZone(value); err != nil {
+			return &FieldError{field, CodeInvalidTimeZone, err.Error()}
+		}
+		return nil
+	}
+}
+
+// localLayouts su oblici vremena bez zone koje prihvaćamo uz zadanu zonu
+var localLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// parseRaceTime pretvara vrijeme početka ili kraja utrke u tip Time u UTC-u.
+// Prihvaća samo RFC 3339, a vrijeme bez zone samo ako je zadana zona loc.
+// Ne pogađamo oblik vremena, jer je npr. 03/04/2019 i 3. travnja i 4. ožujka.
+func parseRaceTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	if loc != nil {
+		for _, layout := range localLayouts {
+			if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+				return t.UTC(), nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("vrijeme %q mora biti u RFC 3339 obliku ili u obliku 2006-01-02T15:04:05", value)
+	}
+	return time.Time{}, fmt.Errorf("vrijeme %q mora biti u RFC 3339 obliku, npr. 2006-01-02T15:04:05+02:00, ili bez zone uz zadan tz", value)
+}
+
+// loadTimeZone učitava vremensku zonu. Prazan naziv znači da zona nije zadana.
+func loadTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("nepoznata vremenska zona %q", name)
+	}
+	return loc, nil
+}
+
+// raceFields vraća polja utrke s pravilima koja moraju zadovoljiti.
+// Ako zona nije ispravna, vremena bez zone se ne prihvaćaju.
+func raceFields(req RaceRequest) []Field {
+	loc, _ := loadTimeZone(req.TZ)
+	return []Field{
+		{"name", req.Name, []Rule{Required(), MaxLength(MaxRaceNameLength)}},
+		{"lat", string(req.Lat), []Rule{Required(), FloatRange(-90, 90)}},
+		{"lon", string(req.Lon), []Rule{Required(), FloatRange(-180, 180)}},
+		{"start", req.Start, []Rule{Required(), DateTime(loc)}},
+		{"end", req.End, []Rule{Required(), DateTime(loc)}},
+		{"tz", req.TZ, []Rule{TimeZone()}},
+	}
+}
+
+// ValidateRace provjerava podatke utrke za dodavanje, ažuriranje i uvoz
+// utrka. Vraća vrijeme početka i kraja utrke u UTC-u i sve pronađene greške.
+func ValidateRace(req RaceRequest) (start, end time.Time, errs ValidationErrors) {
+
+	errs = Validate(raceFields(req)...)
+	if len(errs) > 0 {
+		return start, end, errs
+	}
+
+	// Pravila za polja su prošla pa su zona i oba vremena ispravni
+	loc, _ := loadTimeZone(req.TZ)
+	start, _ = parseRaceTime(req.Start, loc)
+	end, _ = parseRaceTime(req.End, loc)
+
+	now := time.Now()
+	if start.Before(now) {
+		errs = append(errs, FieldError{"start", CodeInPast, "utrka se ne može održavati u prošlosti"})
+	}
+	if end.Before(now) {
+		errs = append(errs, FieldError{"end", CodeInPast, "utrka se ne može održavati u prošlosti"})
+	}
+	if !end.After(start) {
+		errs = append(errs, FieldError{"end", CodeEndBeforeStart, "utrka mora završiti nakon što je počela"})
+	}
+	return start, end, errs
+}
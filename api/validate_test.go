@@ -0,0 +1,157 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFloatRange(t *testing.T) {
+	rule := FloatRange(-90, 90)
+	tests := []struct {
+		value string
+		code  string
+	}{
+		{"45", ""},
+		{"-45.815", ""},
+		{"+1.5", ""},
+		{".5", ""},
+		{"5.", ""},
+		{"90", ""},
+		{"90.0001", CodeOutOfRange},
+		{"-1000", CodeOutOfRange},
+		{"", CodeNotANumber},
+		{"sjever", CodeNotANumber},
+		{"-", CodeNotANumber},
+		{".", CodeNotANumber},
+		{"1.2.3", CodeNotANumber},
+		{"NaN", CodeNotANumber},
+		{"nan", CodeNotANumber},
+		{"Inf", CodeNotANumber},
+		{"-Infinity", CodeNotANumber},
+		{"0x1p4", CodeNotANumber},
+		{"1e1", CodeNotANumber},
+		{"4_5", CodeNotANumber},
+		{" 45", CodeNotANumber},
+		{"45,8", CodeNotANumber},
+	}
+	for _, tt := range tests {
+		fe := rule("lat", tt.value)
+		switch {
+		case tt.code == "" && fe != nil:
+			t.Errorf("FloatRange(%q): unexpected error %+v", tt.value, *fe)
+		case tt.code != "" && (fe == nil || fe.Code != tt.code):
+			t.Errorf("FloatRange(%q) = %+v, want code %s", tt.value, fe, tt.code)
+		}
+	}
+}
+
+func TestValidateReturnsFirstErrorPerField(t *testing.T) {
+	errs := Validate(
+		Field{"name", "", []Rule{Required(), MaxLength(3)}},
+		Field{"lat", "abc", []Rule{Required(), FloatRange(-90, 90)}},
+		Field{"lon", "15.98", []Rule{Required(), FloatRange(-180, 180)}},
+		Field{"city", "Zagreb", []Rule{Required(), MaxLength(3)}},
+	)
+	codes := make([]string, len(errs))
+	for i, fe := range errs {
+		codes[i] = fe.Field + ":" + fe.Code
+	}
+	if got := strings.Join(codes, ","); got != "name:required,lat:not_a_number,city:too_long" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestParseRaceTime(t *testing.T) {
+	zagreb, err := time.LoadLocation("Europe/Zagreb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2030, 5, 4, 6, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		loc   *time.Location
+		ok    bool
+	}{
+		{"2030-05-04T08:00:00+02:00", nil, true},
+		{"2030-05-04T06:00:00Z", zagreb, true},
+		{"2030-05-04T08:00:00", zagreb, true},
+		{"2030-05-04 08:00", zagreb, true},
+		{"2030-05-04T08:00:00", nil, false},
+		{"04.05.2030. 08:00", zagreb, false},
+		{"03/04/2019", zagreb, false},
+	}
+	for _, tt := range tests {
+		got, err := parseRaceTime(tt.value, tt.loc)
+		if tt.ok && (err != nil || !got.Equal(want) || got.Location() != time.UTC) {
+			t.Errorf("parseRaceTime(%q): got %v, %v", tt.value, got, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("parseRaceTime(%q) accepted an ambiguous time", tt.value)
+		}
+	}
+}
+
+func TestValidateRace(t *testing.T) {
+	year := time.Now().Year() + 1
+	valid := RaceRequest{
+		Name:  "Plitvički maraton",
+		Lat:   "44.88",
+		Lon:   "15.62",
+		Start: time.Date(year, 5, 4, 8, 0, 0, 0, time.UTC).Format(time.RFC3339),
+		End:   time.Date(year, 5, 4, 14, 0, 0, 0, time.UTC).Format(time.RFC3339),
+	}
+	start, end, errs := ValidateRace(valid)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	if start.Location() != time.UTC || start.Hour() != 8 || end.Sub(start) != 6*time.Hour {
+		t.Errorf("got %v - %v", start, end)
+	}
+
+	// Vrijeme bez zone prihvaćamo samo uz zonu utrke
+	local := valid
+	local.Start = strings.TrimSuffix(valid.Start, ":00Z")
+	local.End = strings.TrimSuffix(valid.End, ":00Z")
+	if _, _, errs := ValidateRace(local); len(errs) != 2 || errs[0].Code != CodeInvalidDateTime {
+		t.Errorf("time without zone: got %v", errs)
+	}
+	local.TZ = "Europe/Zagreb"
+	if start, _, errs := ValidateRace(local); len(errs) > 0 || start.Hour() != 6 {
+		t.Errorf("time in Europe/Zagreb: got %v, %v", start, errs)
+	}
+
+	tests := []struct {
+		change func(r *RaceRequest)
+		want   string
+	}{
+		{func(r *RaceRequest) { r.Name = "" }, "name:required"},
+		{func(r *RaceRequest) { r.Name = strings.Repeat("č", MaxRaceNameLength+1) }, "name:too_long"},
+		{func(r *RaceRequest) { r.Lat = "91" }, "lat:out_of_range"},
+		{func(r *RaceRequest) { r.Lon = "istok" }, "lon:not_a_number"},
+		{func(r *RaceRequest) { r.Lon = "NaN" }, "lon:not_a_number"},
+		{func(r *RaceRequest) { r.Start = "sutra" }, "start:invalid_datetime"},
+		{func(r *RaceRequest) { r.Start = "03/04/2019" }, "start:invalid_datetime"},
+		{func(r *RaceRequest) { r.TZ = "Europe/Nepostojeci" }, "tz:invalid_timezone"},
+		{func(r *RaceRequest) { r.End = r.Start }, "end:end_before_start"},
+	}
+	for _, tt := range tests {
+		req := valid
+		tt.change(&req)
+		_, _, errs := ValidateRace(req)
+		if len(errs) != 1 || errs[0].Field+":"+errs[0].Code != tt.want {
+			t.Errorf("want %s, got %v", tt.want, errs)
+		}
+	}
+
+	// Sve greške vraćamo odjednom, a zona utrke nije obavezna
+	req := RaceRequest{Lat: "91", Start: "2001-05-04T08:00:00Z", End: "2001-05-04T07:00:00Z"}
+	if _, _, errs := ValidateRace(req); len(errs) != 3 {
+		t.Errorf("got %v, want name, lat and lon errors", errs)
+	}
+	req = valid
+	req.Start, req.End = "2001-05-04T08:00:00Z", "2001-05-04T07:00:00Z"
+	if _, _, errs := ValidateRace(req); len(errs) != 3 || errs[0].Code != CodeInPast || errs[2].Code != CodeEndBeforeStart {
+		t.Errorf("got %v, want two in_past errors and end_before_start", errs)
+	}
+}
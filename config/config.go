@@ -0,0 +1,425 @@
+// Package config učitava postavke aplikacije. Postavke se prvo
+// postavljaju na zadane vrijednosti, zatim se čitaju iz YAML datoteke,
+// pa iz sistemskih varijabli i na kraju iz argumenata komandne linije.
+// Svaki idući izvor ima prednost pred prethodnim.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	// Biblioteka za čitanje YAML datoteka
+	"gopkg.in/yaml.v2"
+)
+
+// Config su sve postavke aplikacije
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Database  Database  `yaml:"database"`
+	Weather   Weather   `yaml:"weather"`
+	Scheduler Scheduler `yaml:"scheduler"`
+}
+
+// Server su postavke HTTP poslužitelja
+type Server struct {
+	Port int    `yaml:"port"`
+	Mode string `yaml:"mode"`
+}
+
+// Database su postavke konekcije prema bazi
+type Database struct {
+	Host         string `yaml:"host"`
+	Port         int    `yaml:"port"`
+	User         string `yaml:"user"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+	Name         string `yaml:"name"`
+	SSLMode      string `yaml:"sslmode"`
+}
+
+// Weather su postavke izvora vremenske prognoze
+type Weather struct {
+	Provider              string `yaml:"provider"`
+	OpenWeatherAPIKey     string `yaml:"openweather_api_key"`
+	OpenWeatherAPIKeyFile string `yaml:"openweather_api_key_file"`
+	// OpenWeatherOneCall uključuje satnu i dnevnu prognozu One Call API-ja
+	OpenWeatherOneCall bool   `yaml:"openweather_onecall"`
+	MetNoUserAgent     string `yaml:"metno_user_agent"`
+	// RateLimits su ograničenja poziva po nazivu izvora prognoze
+	RateLimits map[string]RateLimit `yaml:"rate_limits"`
+	// CacheTTL je trajanje zapamćene prognoze za točke koje nisu utrke
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+// RateLimit je dozvoljeni broj poziva izvora u minuti
+// i najveći broj poziva koji se mogu napraviti odjednom
+type RateLimit struct {
+	PerMinute float64 `yaml:"per_minute"`
+	Burst     int     `yaml:"burst"`
+}
+
+// Scheduler su intervali automatskih zadaća
+type Scheduler struct {
+	UpdateInterval  time.Duration `yaml:"update_interval"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval"`
+}
+
+// Errors su sve greške pronađene pri učitavanju postavki,
+// kako bi ih korisnik mogao sve ispraviti odjednom.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = "  - " + err.Error()
+	}
+	return "neispravne postavke:\n" + strings.Join(msgs, "\n")
+}
+
+// Default vraća zadane postavke
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Port: 8080,
+			Mode: "debug",
+		},
+		Database: Database{
+			Host:    "localhost",
+			Port:    5432,
+			SSLMode: "disable",
+		},
+		Weather: Weather{
+			Provider: "openmeteo",
+			CacheTTL: 30 * time.Minute,
+		},
+		Scheduler: Scheduler{
+			UpdateInterval:  6 * time.Hour,
+			CleanupInterval: time.Hour,
+		},
+	}
+}
+
+// Load učitava postavke iz datoteke, sistemskih varijabli i argumenata
+// komandne linije te ih provjerava. Putanja do datoteke zadaje se
+// argumentom -config ili varijablom CONFIG_FILE.
+func Load(args []string) (*Config, error) {
+	conf := Default()
+	var errs Errors
+
+	fs := flag.NewFlagSet("weather_api", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("CONFIG_FILE"), "putanja do YAML datoteke s postavkama")
+	flags := conf.flagSet(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *path != "" {
+		if err := conf.loadFile(*path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, conf.loadEnv()...)
+	errs = append(errs, flags.apply(conf)...)
+	errs = append(errs, conf.loadSecrets()...)
+	errs = append(errs, conf.Validate()...)
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return conf, nil
+}
+
+// loadFile čita postavke iz YAML datoteke
+func (conf *Config) loadFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("datoteka s postavkama: %v", err)
+	}
+	if err := yaml.Unmarshal(b, conf); err != nil {
+		return fmt.Errorf("datoteka s postavkama %s: %v", path, err)
+	}
+	return nil
+}
+
+// loadEnv čita postavke iz sistemskih varijabli
+func (conf *Config) loadEnv() (errs Errors) {
+	envString := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+	envInt := func(name string, dst *int) {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s mora biti cijeli broj, a ne %q", name, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	envBool := func(name string, dst *bool) {
+		if v, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s mora biti true ili false, a ne %q", name, v))
+				return
+			}
+			*dst = b
+		}
+	}
+	envDuration := func(name string, dst *time.Duration) {
+		if v, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s mora biti trajanje, npr. 6h, a ne %q", name, v))
+				return
+			}
+			*dst = d
+		}
+	}
+	// Tajna se može zadati izravno ili putanjom do datoteke,
+	// a ono što je zadano kasnije ima prednost.
+	envSecret := func(name string, dst, file *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst, *file = v, ""
+		}
+		if v, ok := os.LookupEnv(name + "_FILE"); ok {
+			*dst, *file = "", v
+		}
+	}
+
+	envInt("PORT", &conf.Server.Port)
+	envString("GIN_MODE", &conf.Server.Mode)
+	envString("DBHOST", &conf.Database.Host)
+	envInt("DBPORT", &conf.Database.Port)
+	envString("DBUSER", &conf.Database.User)
+	envSecret("DBPASS", &conf.Database.Password, &conf.Database.PasswordFile)
+	envString("DBNAME", &conf.Database.Name)
+	envString("DBSSLMODE", &conf.Database.SSLMode)
+	envString("WEATHER_PROVIDER", &conf.Weather.Provider)
+	envSecret("OWM_API_KEY", &conf.Weather.OpenWeatherAPIKey, &conf.Weather.OpenWeatherAPIKeyFile)
+	envBool("OWM_ONECALL", &conf.Weather.OpenWeatherOneCall)
+	envString("METNO_USER_AGENT", &conf.Weather.MetNoUserAgent)
+	envDuration("CACHE_TTL", &conf.Weather.CacheTTL)
+	envDuration("UPDATE_INTERVAL", &conf.Scheduler.UpdateInterval)
+	envDuration("CLEANUP_INTERVAL", &conf.Scheduler.CleanupInterval)
+	return errs
+}
+
+// loadSecrets čita tajne zadane putanjom do datoteke
+func (conf *Config) loadSecrets() (errs Errors) {
+	readSecret := func(file string, dst *string) {
+		if file == "" {
+			return
+		}
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		*dst = strings.TrimRight(string(b), "\r\n")
+	}
+
+	readSecret(conf.Database.PasswordFile, &conf.Database.Password)
+	readSecret(conf.Weather.OpenWeatherAPIKeyFile, &conf.Weather.OpenWeatherAPIKey)
+	return errs
+}
+
+// flagValues su vrijednosti argumenata komandne linije
+type flagValues struct {
+	fs     *flag.FlagSet
+	values map[string]*string
+}
+
+// flagSet definira argumente komandne linije za postavke
+func (conf *Config) flagSet(fs *flag.FlagSet) *flagValues {
+	f := &flagValues{fs: fs, values: make(map[string]*string)}
+	define := func(name, usage string) {
+		f.values[name] = fs.String(name, "", usage)
+	}
+	define("port", "port HTTP poslužitelja")
+	define("mode", "gin način rada: debug, release ili test")
+	define("db-host", "adresa baze")
+	define("db-port", "port baze")
+	define("db-user", "korisnik baze")
+	define("db-password-file", "putanja do datoteke s lozinkom baze")
+	define("db-name", "naziv baze")
+	define("db-sslmode", "sslmode konekcije prema bazi")
+	define("provider", "izvor prognoze, npr. openmeteo, metno,openmeteo ili openweather|openmeteo")
+	define("openweather-api-key-file", "putanja do datoteke s Open Weather API ključem")
+	define("openweather-onecall", "true za satnu i dnevnu prognozu Open Weather One Call API-ja")
+	define("metno-user-agent", "User-Agent za MET Norway API")
+	define("cache-ttl", "trajanje zapamćene prognoze za točke, npr. 30m, a 0 isključuje pamćenje")
+	define("update-interval", "interval automatskog ažuriranja prognoza, npr. 6h")
+	define("cleanup-interval", "interval brisanja prošlih prognoza, npr. 1h")
+	return f
+}
+
+// apply postavlja vrijednosti samo onih argumenata koji su zadani
+func (f *flagValues) apply(conf *Config) (errs Errors) {
+	f.fs.Visit(func(fl *flag.Flag) {
+		p, ok := f.values[fl.Name]
+		if !ok {
+			return
+		}
+		v := *p
+
+		setInt := func(dst *int) {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("-%s mora biti cijeli broj, a ne %q", fl.Name, v))
+				return
+			}
+			*dst = n
+		}
+		setDuration := func(dst *time.Duration) {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("-%s mora biti trajanje, npr. 6h, a ne %q", fl.Name, v))
+				return
+			}
+			*dst = d
+		}
+
+		switch fl.Name {
+		case "port":
+			setInt(&conf.Server.Port)
+		case "mode":
+			conf.Server.Mode = v
+		case "db-host":
+			conf.Database.Host = v
+		case "db-port":
+			setInt(&conf.Database.Port)
+		case "db-user":
+			conf.Database.User = v
+		case "db-password-file":
+			conf.Database.Password, conf.Database.PasswordFile = "", v
+		case "db-name":
+			conf.Database.Name = v
+		case "db-sslmode":
+			conf.Database.SSLMode = v
+		case "provider":
+			conf.Weather.Provider = v
+		case "openweather-api-key-file":
+			conf.Weather.OpenWeatherAPIKey, conf.Weather.OpenWeatherAPIKeyFile = "", v
+		case "openweather-onecall":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("-%s mora biti true ili false, a ne %q", fl.Name, v))
+				return
+			}
+			conf.Weather.OpenWeatherOneCall = b
+		case "metno-user-agent":
+			conf.Weather.MetNoUserAgent = v
+		case "cache-ttl":
+			setDuration(&conf.Weather.CacheTTL)
+		case "update-interval":
+			setDuration(&conf.Scheduler.UpdateInterval)
+		case "cleanup-interval":
+			setDuration(&conf.Scheduler.CleanupInterval)
+		}
+	})
+	return errs
+}
+
+// providers su nazivi izvora prognoze koje aplikacija poznaje
+var providers = map[string]bool{
+	"openweather": true,
+	"openmeteo":   true,
+	"metno":       true,
+}
+
+// Validate provjerava postavke i vraća sve pronađene greške
+func (conf *Config) Validate() (errs Errors) {
+	if conf.Server.Port < 1 || conf.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port mora biti između 1 i 65535, a ne %d", conf.Server.Port))
+	}
+	switch conf.Server.Mode {
+	case "debug", "release", "test":
+	default:
+		errs = append(errs, fmt.Errorf("server.mode mora biti debug, release ili test, a ne %q", conf.Server.Mode))
+	}
+
+	if conf.Database.Host == "" {
+		errs = append(errs, errors.New("database.host (DBHOST) nije zadan"))
+	}
+	if conf.Database.Port < 1 || conf.Database.Port > 65535 {
+		errs = append(errs, fmt.Errorf("database.port mora biti između 1 i 65535, a ne %d", conf.Database.Port))
+	}
+	if conf.Database.User == "" {
+		errs = append(errs, errors.New("database.user (DBUSER) nije zadan"))
+	}
+	if conf.Database.Name == "" {
+		errs = append(errs, errors.New("database.name (DBNAME) nije zadan"))
+	}
+	switch conf.Database.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		errs = append(errs, fmt.Errorf("database.sslmode %q nije podržan", conf.Database.SSLMode))
+	}
+
+	usesOpenWeather := false
+	for _, name := range strings.FieldsFunc(conf.Weather.Provider, func(r rune) bool { return r == ',' || r == '|' }) {
+		name = strings.TrimSpace(name)
+		if !providers[name] {
+			errs = append(errs, fmt.Errorf("weather.provider: nepoznat izvor prognoze %q", name))
+		}
+		usesOpenWeather = usesOpenWeather || name == "openweather"
+	}
+	if conf.Weather.Provider == "" {
+		errs = append(errs, errors.New("weather.provider nije zadan"))
+	}
+	if usesOpenWeather && conf.Weather.OpenWeatherAPIKey == "" {
+		errs = append(errs, errors.New("weather.openweather_api_key (OWM_API_KEY) je obavezan za openweather izvor"))
+	}
+
+	for name, limit := range conf.Weather.RateLimits {
+		if !providers[name] {
+			errs = append(errs, fmt.Errorf("weather.rate_limits: nepoznat izvor prognoze %q", name))
+		}
+		if limit.PerMinute <= 0 {
+			errs = append(errs, fmt.Errorf("weather.rate_limits.%s.per_minute mora biti veći od 0", name))
+		}
+		if limit.Burst < 1 {
+			errs = append(errs, fmt.Errorf("weather.rate_limits.%s.burst mora biti barem 1", name))
+		}
+	}
+
+	if conf.Weather.CacheTTL < 0 {
+		errs = append(errs, fmt.Errorf("weather.cache_ttl ne može biti negativan, a zadan je %v", conf.Weather.CacheTTL))
+	}
+
+	if conf.Scheduler.UpdateInterval < time.Minute || conf.Scheduler.UpdateInterval%time.Minute != 0 {
+		errs = append(errs, fmt.Errorf("scheduler.update_interval mora biti cijeli broj minuta, a ne %v", conf.Scheduler.UpdateInterval))
+	}
+	if conf.Scheduler.CleanupInterval < time.Minute || conf.Scheduler.CleanupInterval%time.Minute != 0 {
+		errs = append(errs, fmt.Errorf("scheduler.cleanup_interval mora biti cijeli broj minuta, a ne %v", conf.Scheduler.CleanupInterval))
+	}
+	return errs
+}
+
+// DSN vraća podatke za konekciju prema bazi u obliku koji razumije lib/pq
+func (db Database) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quote(db.Host), db.Port, quote(db.User), quote(db.Password), quote(db.Name), quote(db.SSLMode))
+}
+
+// quote stavlja vrijednost u navodnike, kako bi lozinke
+// s razmacima ili navodnicima ispravno prošle
+func quote(v string) string {
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, `'`, `\'`, -1)
+	return "'" + v + "'"
+}
+
+// Address vraća adresu na kojoj poslužitelj sluša
+func (s Server) Address() string {
+	return ":" + strconv.Itoa(s.Port)
+}
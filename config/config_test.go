@@ -0,0 +1,152 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setEnv postavlja sistemske varijable i vraća funkciju koja ih vraća na staro
+func setEnv(vars map[string]string) (restore func()) {
+	old := make(map[string]*string)
+	for name, v := range vars {
+		if prev, ok := os.LookupEnv(name); ok {
+			old[name] = &prev
+		} else {
+			old[name] = nil
+		}
+		os.Setenv(name, v)
+	}
+	return func() {
+		for name, prev := range old {
+			if prev == nil {
+				os.Unsetenv(name)
+			} else {
+				os.Setenv(name, *prev)
+			}
+		}
+	}
+}
+
+// writeFile sprema sadržaj u datoteku u privremenom direktoriju
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeFile(t, dir, "config.yaml", `
+server:
+  port: 9000
+  mode: release
+database:
+  host: baza
+  user: datoteka
+  name: utrke
+weather:
+  provider: metno
+  rate_limits:
+    metno:
+      per_minute: 120
+      burst: 4
+scheduler:
+  update_interval: 3h
+`)
+	secret := writeFile(t, dir, "dbpass", "tajna lozinka\n")
+
+	// Varijable imaju prednost pred datotekom, a argumenti pred varijablama
+	defer setEnv(map[string]string{
+		"DBUSER":      "varijabla",
+		"DBPASS_FILE": secret,
+		"PORT":        "9100",
+		"CACHE_TTL":   "5m",
+	})()
+	conf, err := Load([]string{"-config", path, "-port", "9200", "-cleanup-interval", "30m", "-openweather-onecall", "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Server.Port != 9200 || conf.Server.Mode != "release" {
+		t.Errorf("unexpected server %+v", conf.Server)
+	}
+	db := conf.Database
+	if db.Host != "baza" || db.Port != 5432 || db.User != "varijabla" || db.Password != "tajna lozinka" {
+		t.Errorf("unexpected database %+v", db)
+	}
+	if conf.Weather.Provider != "metno" || conf.Weather.RateLimits["metno"] != (RateLimit{120, 4}) ||
+		!conf.Weather.OpenWeatherOneCall || conf.Weather.CacheTTL != 5*time.Minute {
+		t.Errorf("unexpected weather %+v", conf.Weather)
+	}
+	if conf.Scheduler.UpdateInterval != 3*time.Hour || conf.Scheduler.CleanupInterval != 30*time.Minute {
+		t.Errorf("unexpected scheduler %+v", conf.Scheduler)
+	}
+}
+
+func TestLoadReportsAllErrors(t *testing.T) {
+	defer setEnv(map[string]string{
+		"DBPORT":           "pet",
+		"DBUSER":           "",
+		"DBNAME":           "",
+		"WEATHER_PROVIDER": "openweather|nepostojeci",
+		"OWM_API_KEY":      "",
+		"OWM_ONECALL":      "možda",
+	})()
+	_, err := Load([]string{"-mode", "produkcija", "-update-interval", "90s", "-cache-ttl", "-1m", "-config", ""})
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("got %v, want Errors", err)
+	}
+
+	// Sve greške se vraćaju odjednom
+	msg := errs.Error()
+	for _, want := range []string{"DBPORT", "server.mode", "database.user", "database.name",
+		"nepostojeci", "openweather_api_key", "OWM_ONECALL", "update_interval", "cache_ttl"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error does not mention %s:\n%s", want, msg)
+		}
+	}
+}
+
+func TestValidateRateLimits(t *testing.T) {
+	conf := Default()
+	conf.Database.User, conf.Database.Name = "admin", "utrke"
+	conf.Weather.RateLimits = map[string]RateLimit{
+		"openmeteo":   {PerMinute: 100, Burst: 10},
+		"metno":       {PerMinute: 0, Burst: 0},
+		"nepostojeci": {PerMinute: 1, Burst: 1},
+	}
+
+	var msgs []string
+	for _, err := range conf.Validate() {
+		msgs = append(msgs, err.Error())
+	}
+	got := strings.Join(msgs, "\n")
+	for _, want := range []string{"metno.per_minute", "metno.burst", `"nepostojeci"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("errors do not mention %s:\n%s", want, got)
+		}
+	}
+	if len(msgs) != 3 {
+		t.Errorf("got %d errors, want 3:\n%s", len(msgs), got)
+	}
+}
+
+func TestDSN(t *testing.T) {
+	db := Database{Host: "localhost", Port: 5432, User: "admin", Password: `a b'c\`, Name: "utrke", SSLMode: "disable"}
+	want := `host='localhost' port=5432 user='admin' password='a b\'c\\' dbname='utrke' sslmode='disable'`
+	if got := db.DSN(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
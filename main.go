@@ -4,8 +4,10 @@ import (
 	"io"
 	"log"
 	"os"
+	"time"
 
 	"weather_api/api"
+	"weather_api/config"
 	// Jednostavan i brz HTTP web framework
 	"github.com/gin-gonic/gin"
 	"github.com/jasonlvhit/gocron"
@@ -15,8 +17,7 @@ import (
 
 // export DBUSER="weather_api_user"; export DBPASS=jud34DZ1; export DBHOST="localhost"; export DBNAME="weather_api_db"; export DBPORT="5432";
 
-func initializeRoutes() *gin.Engine {
-	gin.SetMode(gin.DebugMode)
+func initializeRoutes(service *api.RaceService) *gin.Engine {
 	router := gin.Default()
 	router.Use(
 		// Logger middleware will write the logs to gin.DefaultWriter even if you set with GIN_MODE=release.
@@ -25,17 +26,47 @@ func initializeRoutes() *gin.Engine {
 		// Recovery middleware sprječava zastoj u slučaju panic-a i zapisuje 500 ako postoji jedan takav.
 		gin.Recovery(),
 	)
+
 	// Grupiranje ruta pod dodatnu rutu api omogućava nam da izbjegnemo sukob
 	// sa drugim rutama web aplikacije, a podruta v1 nam omogućava,
 	// u slučaju kasnije nadogradnje api-ja, lakše prebacivanje na njegove različite verzije.
 	v1 := router.Group("api/v1")
 	{
-		v1.GET("/race/:id/forecast", api.GetWeatherHandler)
-		v1.GET("/races", api.GetAllRacesHandler)
-		v1.POST("/race", api.CreateRaceHandler)
-		v1.GET("/race/:id", api.GetRaceHandler)
-		v1.PUT("/race/:id", api.UpdateRaceHandler)
-		v1.DELETE("/race/:id", api.DeleteRaceHandler)
+		v1.GET("/forecast", api.GetPointForecastHandler(service))
+		v1.POST("/forecast/batch", api.BatchForecastHandler(service))
+		v1.GET("/race/:id/forecast", api.GetWeatherHandler(service))
+		v1.GET("/race/:id/forecast/summary", api.GetWeatherSummaryHandler(service))
+		v1.GET("/races", api.GetAllRacesHandler(service))
+		v1.GET("/races/nearby", api.NearbyRacesHandler(service))
+		v1.GET("/races/within", api.RacesWithinHandler(service))
+		v1.POST("/race", api.CreateRaceHandler(service))
+		v1.POST("/races/import", api.ImportRacesHandler(service))
+		v1.GET("/race/:id", api.GetRaceHandler(service))
+		v1.PUT("/race/:id", api.UpdateRaceHandler(service))
+		v1.PATCH("/race/:id", api.PatchRaceHandler(service))
+		v1.DELETE("/race/:id", api.DeleteRaceHandler(service))
+		v1.GET("/admin/providers", api.ProviderStatusHandler(service.Provider))
+		v1.GET("/admin/ratelimits", api.RateLimitStatusHandler)
+		v1.GET("/admin/retries", api.RetryQueueHandler)
+	}
+
+	// API v2 ima engleske nazive polja i omotnicu odgovora
+	v2 := router.Group(api.V2Prefix)
+	{
+		v2.GET("/races", api.ListRacesV2Handler(service))
+		v2.POST("/races", api.CreateRaceV2Handler(service))
+		v2.POST("/races/import", api.ImportRacesV2Handler(service))
+		v2.GET("/races/:id", api.GetRaceV2Handler(service))
+		v2.PUT("/races/:id", api.UpdateRaceV2Handler(service))
+		v2.PATCH("/races/:id", api.PatchRaceV2Handler(service))
+		v2.DELETE("/races/:id", api.DeleteRaceV2Handler(service))
+		v2.GET("/races/:id/forecast", api.GetForecastV2Handler(service))
+		v2.GET("/races/:id/forecast/summary", api.GetSummaryV2Handler(service))
+		v2.GET("/forecast", api.GetPointForecastV2Handler(service))
+		v2.POST("/forecast/batch", api.BatchForecastV2Handler(service))
+		// Putanja /races/nearby bi bila u sukobu s /races/:id
+		v2.GET("/search/nearby", api.NearbyRacesV2Handler(service))
+		v2.GET("/search/within", api.RacesWithinV2Handler(service))
 	}
 
 	return router
@@ -51,22 +82,54 @@ func main() {
 	f, _ := os.Create("gin.log")
 	gin.DefaultWriter = io.MultiWriter(f)
 
+	// Učitavanje postavki iz datoteke, sistemskih varijabli i argumenata.
+	// Sve neispravne postavke ispisujemo odjednom.
+	conf, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	gin.SetMode(conf.Server.Mode)
+
+	// Izvor vremenske prognoze koji koriste handleri i automatsko ažuriranje.
+	rateLimits := make(map[string]api.RateLimit)
+	for name, limit := range conf.Weather.RateLimits {
+		rateLimits[name] = api.RateLimit{PerMinute: limit.PerMinute, Burst: limit.Burst}
+	}
+	provider, err := api.NewProvider(conf.Weather.Provider, api.ProviderOptions{
+		OpenWeatherAPIKey:  conf.Weather.OpenWeatherAPIKey,
+		OpenWeatherOneCall: conf.Weather.OpenWeatherOneCall,
+		MetNoUserAgent:     conf.Weather.MetNoUserAgent,
+		RateLimits:         rateLimits,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Obje verzije API-ja koriste isti servis, pa se razlikuju samo u obliku odgovora
+	service := api.NewRaceService(provider, conf.Weather.CacheTTL)
+
 	// Inicijalizacija rutera
-	router := initializeRoutes()
+	router := initializeRoutes(service)
 	// Incijalizacije konekcije prema bazi
-	api.InitializeDb()
+	if err := api.InitializeDb(conf.Database.DSN()); err != nil {
+		log.Fatal(err)
+	}
+
+	// Lokacije dodane prije nego što smo pamtili vremenske zone
+	// dobivaju zonu prema svojim koordinatama
+	if err := api.FillLocationZones(); err != nil {
+		log.Printf("Neuspješno postavljanje vremenskih zona lokacija: %v", err)
+	}
 
 	// Pokretanje procesa koji je zadužen za automatsko
-	// ažuriranje svakih 6 sati i procesa koji se brine o
-	// brisanju forecast za utrke koje su prošle svaki sat vremena.
+	// ažuriranje prognoza i procesa koji se brine o
+	// brisanju forecast za utrke koje su prošle.
 	// Proces za ažuriranje pokrećemo u posebnoj goroutine, kako,
 	// u sluačaju čekanja, kod dohvaćanja ažuriranja forecast, cijeli
 	// api ne bi postao nedostupan.
-	go gocron.Every(6).Hours().Do(api.AutomaticUpdate)
-	gocron.Every(1).Hours().Do(api.DeleteWeatherPodcast)
+	go gocron.Every(uint64(conf.Scheduler.UpdateInterval/time.Minute)).Minutes().Do(api.AutomaticUpdate, provider)
+	gocron.Every(uint64(conf.Scheduler.CleanupInterval / time.Minute)).Minutes().Do(api.DeleteWeatherPodcast)
 	gocron.Start()
 
-	// Po default-u port je :8080 osim ako je
-	// PORT sustavna varijabla definirana drukčije.
-	router.Run()
+	router.Run(conf.Server.Address())
 }